@@ -0,0 +1,69 @@
+package percpu
+
+import (
+	"sync"
+)
+
+// An Accumulator computes a custom mergeable statistic of values added by
+// many goroutines concurrently, for example a bounding box or the top N items.
+//
+// Each shard keeps its own State, which is updated under a per-shard lock.
+// Result merges the states of all shards and turns the merged state into the
+// result.
+//
+// Result does not observe a consistent view of the shards if it is called
+// concurrently to Update.
+type Accumulator[In, State, Out any] struct {
+	update   func(s *State, x In)
+	combine  func(dst, src *State)
+	finalize func(s *State) Out
+	vs       Values[accumulatorShard[State]]
+}
+
+type accumulatorShard[State any] struct {
+	mu sync.Mutex
+	s  State
+}
+
+// NewAccumulator returns a fresh Accumulator. Every shard starts with the zero
+// State.
+//
+// update adds x to the state of one shard. combine merges src into dst; it is
+// called with the zero State as the first dst and must not retain src.
+// finalize computes the result from the merged state.
+// The functions are called with a shard locked, so they must not call methods
+// of the Accumulator.
+// The options configure the sharding of the Accumulator.
+func NewAccumulator[In, State, Out any](
+	update func(s *State, x In),
+	combine func(dst, src *State),
+	finalize func(s *State) Out,
+	opts ...Option,
+) *Accumulator[In, State, Out] {
+	a := &Accumulator[In, State, Out]{
+		update:   update,
+		combine:  combine,
+		finalize: finalize,
+	}
+	a.vs.opts.apply(opts)
+	return a
+}
+
+// Update adds x to the state of the current shard.
+func (a *Accumulator[In, State, Out]) Update(x In) {
+	s := a.vs.Get()
+	s.mu.Lock()
+	a.update(&s.s, x)
+	s.mu.Unlock()
+}
+
+// Result merges the states of all shards and returns the finalized result.
+func (a *Accumulator[In, State, Out]) Result() Out {
+	var merged State
+	a.vs.Range(func(s *accumulatorShard[State]) {
+		s.mu.Lock()
+		a.combine(&merged, &s.s)
+		s.mu.Unlock()
+	})
+	return a.finalize(&merged)
+}
@@ -0,0 +1,40 @@
+package percpu
+
+import (
+	"sort"
+	"testing"
+)
+
+// top3 keeps the largest three values.
+type top3 []int
+
+func (t *top3) add(x int) {
+	*t = append(*t, x)
+	sort.Sort(sort.Reverse(sort.IntSlice(*t)))
+	if len(*t) > 3 {
+		*t = (*t)[:3]
+	}
+}
+
+func TestAccumulator(t *testing.T) {
+	setProcIDs(t, 2, 0, 1, 0, 1, 0, 1)
+	a := NewAccumulator(
+		func(s *top3, x int) { s.add(x) },
+		func(dst, src *top3) {
+			for _, x := range *src {
+				dst.add(x)
+			}
+		},
+		func(s *top3) []int { return *s },
+	)
+	if got := a.Result(); len(got) != 0 {
+		t.Fatalf("got %v before Update; want empty", got)
+	}
+	for _, x := range []int{5, 9, 1, 7, 8, 2} {
+		a.Update(x)
+	}
+	got := a.Result()
+	if len(got) != 3 || got[0] != 9 || got[1] != 8 || got[2] != 7 {
+		t.Fatalf("got %v; want [9 8 7]", got)
+	}
+}
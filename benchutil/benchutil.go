@@ -0,0 +1,179 @@
+// Package benchutil measures how implementations of a shared data structure
+// scale with the number of goroutines using it concurrently.
+//
+// It is meant to help decide whether converting a contended value (a mutex or
+// an atomic) to a sharded percpu type pays off on a particular machine and
+// workload, by comparing both implementations side by side.
+package benchutil
+
+import (
+	"fmt"
+	"io"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"text/tabwriter"
+	"time"
+)
+
+// Impl is an implementation under test.
+type Impl struct {
+	// Name identifies the implementation in the report.
+	Name string
+	// New creates a fresh instance of the implementation and returns
+	// a function running one operation on it. The returned function is called
+	// concurrently from many goroutines.
+	New func() func()
+}
+
+// Config configures Compare. The zero value selects the defaults.
+type Config struct {
+	// Goroutines lists the numbers of concurrent goroutines to measure.
+	// Defaults to powers of two up to twice GOMAXPROCS.
+	Goroutines []int
+	// Duration is how long each measurement runs. Defaults to 100ms.
+	Duration time.Duration
+}
+
+// Result is a single measurement.
+type Result struct {
+	// Impl is the name of the implementation.
+	Impl string
+	// Goroutines is the number of goroutines running operations concurrently.
+	Goroutines int
+	// Ops is the number of operations completed.
+	Ops uint64
+	// Elapsed is the duration of the measurement.
+	Elapsed time.Duration
+}
+
+// OpsPerSec returns the throughput of all goroutines combined.
+func (r Result) OpsPerSec() float64 {
+	if r.Elapsed <= 0 {
+		return 0
+	}
+	return float64(r.Ops) / r.Elapsed.Seconds()
+}
+
+// NsPerOp returns the average time a goroutine spent in one operation.
+func (r Result) NsPerOp() float64 {
+	if r.Ops == 0 {
+		return 0
+	}
+	return float64(r.Elapsed.Nanoseconds()) * float64(r.Goroutines) / float64(r.Ops)
+}
+
+// Report holds the results of Compare.
+type Report struct {
+	// Results are ordered by implementation, then by number of goroutines.
+	Results []Result
+}
+
+// Inflation returns how many times slower a single operation of r is than
+// the same operation of the same implementation running on one goroutine.
+//
+// As long as there are no more goroutines than CPUs, an operation that does
+// not contend with other goroutines keeps an inflation close to 1. Inflation
+// growing with the number of goroutines is a proxy for cache-line
+// contention (cache misses on shared data).
+// It returns 0 if there is no single-goroutine measurement for the
+// implementation.
+func (rep *Report) Inflation(r Result) float64 {
+	for _, base := range rep.Results {
+		if base.Impl == r.Impl && base.Goroutines == 1 && base.NsPerOp() > 0 {
+			return r.NsPerOp() / base.NsPerOp()
+		}
+	}
+	return 0
+}
+
+// WriteTo writes the report as a table to w.
+// The last column compares the throughput to the first implementation with
+// the same number of goroutines.
+func (rep *Report) WriteTo(w io.Writer) (int64, error) {
+	cw := &countingWriter{w: w}
+	tw := tabwriter.NewWriter(cw, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "impl\tgoroutines\tops/s\tns/op\tinflation\tvs first\t")
+	for _, r := range rep.Results {
+		speedup := 0.0
+		for _, first := range rep.Results {
+			if first.Goroutines == r.Goroutines {
+				if f := first.OpsPerSec(); f > 0 {
+					speedup = r.OpsPerSec() / f
+				}
+				break
+			}
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%.0f\t%.1f\t%.2fx\t%.2fx\t\n",
+			r.Impl, r.Goroutines, r.OpsPerSec(), r.NsPerOp(), rep.Inflation(r), speedup)
+	}
+	err := tw.Flush()
+	return cw.n, err
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// Compare measures each of impls with each number of goroutines in cfg.
+func Compare(cfg Config, impls ...Impl) *Report {
+	if len(cfg.Goroutines) == 0 {
+		for g := 1; g <= 2*runtime.GOMAXPROCS(0); g *= 2 {
+			cfg.Goroutines = append(cfg.Goroutines, g)
+		}
+	}
+	if cfg.Duration <= 0 {
+		cfg.Duration = 100 * time.Millisecond
+	}
+	rep := &Report{}
+	for _, impl := range impls {
+		for _, g := range cfg.Goroutines {
+			rep.Results = append(rep.Results, measure(impl, g, cfg.Duration))
+		}
+	}
+	return rep
+}
+
+func measure(impl Impl, goroutines int, d time.Duration) Result {
+	op := impl.New()
+	var (
+		stop  atomic.Bool
+		total atomic.Uint64
+		wg    sync.WaitGroup
+	)
+	start := make(chan struct{})
+	for i := 0; i < goroutines; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			var n uint64
+			for !stop.Load() {
+				// Amortize the check of the stop flag.
+				for j := 0; j < 64; j++ {
+					op()
+				}
+				n += 64
+			}
+			total.Add(n)
+		}()
+	}
+	begin := time.Now()
+	close(start)
+	time.Sleep(d)
+	stop.Store(true)
+	wg.Wait()
+	return Result{
+		Impl:       impl.Name,
+		Goroutines: goroutines,
+		Ops:        total.Load(),
+		Elapsed:    time.Since(begin),
+	}
+}
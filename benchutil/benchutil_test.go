@@ -0,0 +1,41 @@
+package benchutil
+
+import (
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/martin-sucha/percpu"
+)
+
+func TestCompare(t *testing.T) {
+	rep := Compare(Config{Goroutines: []int{1, 2}, Duration: 10 * time.Millisecond},
+		Impl{Name: "atomic", New: func() func() {
+			var n atomic.Int64
+			return func() { n.Add(1) }
+		}},
+		Impl{Name: "percpu", New: func() func() {
+			c := percpu.NewCounter()
+			return func() { c.Add(1) }
+		}},
+	)
+	if len(rep.Results) != 4 {
+		t.Fatalf("got %d results; want 4", len(rep.Results))
+	}
+	for _, r := range rep.Results {
+		if r.Ops == 0 {
+			t.Fatalf("no operations recorded for %+v", r)
+		}
+		if r.Goroutines == 1 && rep.Inflation(r) != 1 {
+			t.Fatalf("got inflation %v for single goroutine", rep.Inflation(r))
+		}
+	}
+	var b strings.Builder
+	if _, err := rep.WriteTo(&b); err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Count(b.String(), "\n"); lines != 5 {
+		t.Fatalf("got %d lines; want 5:\n%s", lines, b.String())
+	}
+}
@@ -0,0 +1,136 @@
+package percpu
+
+import (
+	"math/bits"
+	"strconv"
+	"sync/atomic"
+)
+
+// A BoundedCounter is an int64 counter whose total saturates at a minimum and
+// a maximum instead of wrapping around, for example to track credits or
+// quotas. It may be efficiently updated by many goroutines concurrently.
+//
+// Each shard is clamped to the bounds on every Add, with a compare-and-swap
+// loop. Because the shards are clamped independently, Load additionally
+// clamps their sum. This means that the total is exactly the saturating sum
+// of all Adds only while all of them land on one shard. For example, with
+// a maximum of 10, adding 8 on two shards and then -5 on one of them results
+// in a total of 10, not 5. Load reports whether any shard or the sum hit a
+// bound, so that callers can tell a saturated total from an exact one.
+//
+// Like Counter, Load and Reset do not observe a consistent view of the total
+// if they are called concurrently to Add.
+//
+// A BoundedCounter must be created with NewBoundedCounter.
+type BoundedCounter struct {
+	min, max int64
+	vs       Values[boundedShard]
+}
+
+type boundedShard struct {
+	v atomic.Int64
+	// saturated is set when an Add on the shard was clamped.
+	saturated atomic.Bool
+}
+
+// NewBoundedCounter returns a fresh BoundedCounter initialized to zero,
+// whose total is kept between min and max, inclusive.
+// The options configure the sharding of the BoundedCounter.
+// It panics unless min <= 0 <= max.
+func NewBoundedCounter(min, max int64, opts ...Option) *BoundedCounter {
+	if min > 0 || max < 0 {
+		panic("percpu: NewBoundedCounter with bounds excluding zero")
+	}
+	c := &BoundedCounter{min: min, max: max}
+	c.vs.opts.apply(opts)
+	return c
+}
+
+// Add adds n to the total count, clamping the shard of the calling goroutine
+// to the bounds.
+func (c *BoundedCounter) Add(n int64) {
+	s := c.vs.Get()
+	for {
+		old := s.v.Load()
+		v, clamped := c.clamp(old, n)
+		if s.v.CompareAndSwap(old, v) {
+			if clamped {
+				s.saturated.Store(true)
+			}
+			return
+		}
+	}
+}
+
+// clamp returns v+n limited to the bounds, and whether it was limited.
+// v must be within the bounds, which the shards always are, so that the
+// distances from v to the bounds fit in a uint64. It does not overflow,
+// even for n of math.MinInt64.
+func (c *BoundedCounter) clamp(v, n int64) (int64, bool) {
+	switch {
+	case n > 0 && uint64(n) > uint64(c.max)-uint64(v):
+		return c.max, true
+	case n < 0 && -uint64(n) > uint64(v)-uint64(c.min):
+		return c.min, true
+	}
+	return v + n, false
+}
+
+// Load computes the total counter value and reports whether the total
+// saturated since the last Reset.
+func (c *BoundedCounter) Load() (total int64, saturated bool) {
+	var sum int128
+	c.vs.Range(func(s *boundedShard) {
+		sum.add(s.v.Load())
+		saturated = saturated || s.saturated.Load()
+	})
+	return c.clampSum(sum, saturated)
+}
+
+// Reset sets the counter to zero, clears the saturation flag and reports the
+// values Load would return.
+func (c *BoundedCounter) Reset() (total int64, saturated bool) {
+	var sum int128
+	c.vs.Range(func(s *boundedShard) {
+		sum.add(s.v.Swap(0))
+		saturated = s.saturated.Swap(false) || saturated
+	})
+	return c.clampSum(sum, saturated)
+}
+
+// String returns the total in decimal, so that BoundedCounter implements
+// expvar.Var.
+func (c *BoundedCounter) String() string {
+	total, _ := c.Load()
+	return strconv.FormatInt(total, 10)
+}
+
+func (c *BoundedCounter) clampSum(sum int128, saturated bool) (int64, bool) {
+	switch v, ok := sum.int64(); {
+	case !ok && sum.hi < 0, ok && v < c.min:
+		return c.min, true
+	case !ok, v > c.max:
+		return c.max, true
+	default:
+		return v, saturated
+	}
+}
+
+// int128 is a signed 128-bit integer, wide enough to sum the shards of
+// a BoundedCounter without overflow.
+type int128 struct {
+	hi int64
+	lo uint64
+}
+
+func (x *int128) add(v int64) {
+	var carry uint64
+	x.lo, carry = bits.Add64(x.lo, uint64(v), 0)
+	x.hi += v>>63 + int64(carry)
+}
+
+// int64 returns x and whether it fits in an int64.
+func (x int128) int64() (int64, bool) {
+	v := int64(x.lo)
+	return v, x.hi == v>>63
+}
@@ -0,0 +1,125 @@
+package percpu
+
+import (
+	"math"
+	"sync"
+	"testing"
+)
+
+func TestBoundedCounter(t *testing.T) {
+	c := NewBoundedCounter(-5, 10, WithShards(1))
+	c.Add(7)
+	if got, sat := c.Load(); got != 7 || sat {
+		t.Fatalf("got %d, %v; want 7, false", got, sat)
+	}
+	c.Add(7)
+	if got, sat := c.Load(); got != 10 || !sat {
+		t.Fatalf("got %d, %v; want 10, true", got, sat)
+	}
+	c.Add(-30)
+	if got, sat := c.Reset(); got != -5 || !sat {
+		t.Fatalf("Reset returned %d, %v; want -5, true", got, sat)
+	}
+	if got, sat := c.Load(); got != 0 || sat {
+		t.Fatalf("got %d, %v after Reset; want 0, false", got, sat)
+	}
+}
+
+func TestBoundedCounterShards(t *testing.T) {
+	setProcIDs(t, 2, 0, 1, 0)
+	c := NewBoundedCounter(0, 10)
+	c.Add(8)
+	c.Add(8)
+	if got, sat := c.Load(); got != 10 || !sat {
+		t.Fatalf("got %d, %v; want 10, true", got, sat)
+	}
+	c.Add(-5)
+	if got, sat := c.Load(); got != 10 || !sat {
+		t.Fatalf("got %d, %v after decrement; want 10, true", got, sat)
+	}
+}
+
+func TestBoundedCounterOverflow(t *testing.T) {
+	c := NewBoundedCounter(math.MinInt64, math.MaxInt64, WithShards(1))
+	c.Add(math.MaxInt64)
+	c.Add(math.MaxInt64)
+	if got, sat := c.Load(); got != math.MaxInt64 || !sat {
+		t.Fatalf("got %d, %v; want MaxInt64, true", got, sat)
+	}
+}
+
+func TestBoundedCounterMinInt64(t *testing.T) {
+	c := NewBoundedCounter(0, 100, WithShards(1))
+	c.Add(5)
+	c.Add(math.MinInt64)
+	c.Add(10)
+	if got, sat := c.Load(); got != 10 || !sat {
+		t.Fatalf("got %d, %v; want 10, true", got, sat)
+	}
+	c = NewBoundedCounter(math.MinInt64, math.MaxInt64, WithShards(1))
+	c.Add(-1)
+	c.Add(math.MinInt64)
+	if got, sat := c.Load(); got != math.MinInt64 || !sat {
+		t.Fatalf("got %d, %v; want MinInt64, true", got, sat)
+	}
+}
+
+func TestBoundedCounterConcurrent(t *testing.T) {
+	c := NewBoundedCounter(0, 1000)
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				c.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	if got, sat := c.Load(); got != 1000 || sat {
+		t.Fatalf("got %d, %v; want 1000, false", got, sat)
+	}
+}
+
+func TestBoundedCounterInvalidBounds(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("no panic for bounds excluding zero")
+		}
+	}()
+	NewBoundedCounter(1, 2)
+}
+
+func TestBoundedCounterSum(t *testing.T) {
+	setProcIDs(t, 3, 0, 1, 2)
+	c := NewBoundedCounter(math.MinInt64, 10)
+	c.Add(8)
+	c.Add(8)
+	c.Add(-10)
+	// Only the sum is clamped, not the partial sums.
+	if got, sat := c.Load(); got != 6 || sat {
+		t.Fatalf("got %d, %v; want 6, false", got, sat)
+	}
+}
+
+func TestInt128(t *testing.T) {
+	var x int128
+	x.add(math.MaxInt64)
+	x.add(math.MaxInt64)
+	if _, ok := x.int64(); ok || x.hi != 0 {
+		t.Fatalf("got %+v; want an overflow to a positive value", x)
+	}
+	x.add(math.MinInt64)
+	x.add(math.MinInt64)
+	x.add(-1)
+	if v, ok := x.int64(); !ok || v != -3 {
+		t.Fatalf("got %d, %v; want -3, true", v, ok)
+	}
+	x = int128{}
+	x.add(math.MinInt64)
+	x.add(-1)
+	if _, ok := x.int64(); ok || x.hi >= 0 {
+		t.Fatalf("got %+v; want an overflow to a negative value", x)
+	}
+}
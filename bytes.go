@@ -0,0 +1,88 @@
+package percpu
+
+import (
+	"strconv"
+	"time"
+)
+
+// A BytesCounter counts bytes, like the amount of data sent over a network,
+// and may be efficiently updated by many goroutines concurrently. It is
+// a Counter with helpers for reporting throughput.
+//
+// Like Counter, Load and Reset do not observe a consistent view of the total
+// if they are called concurrently to Add. See Counter for the guarantees.
+type BytesCounter struct {
+	c Counter
+}
+
+// NewBytesCounter returns a fresh BytesCounter initialized to zero.
+// The options configure the sharding of the BytesCounter and its Clock.
+func NewBytesCounter(opts ...Option) *BytesCounter {
+	b := &BytesCounter{}
+	b.c.vs.opts.apply(opts)
+	return b
+}
+
+// Add adds n bytes to the total.
+func (b *BytesCounter) Add(n int64) {
+	b.c.Add(n)
+}
+
+// Load computes the total number of bytes.
+func (b *BytesCounter) Load() int64 {
+	return b.c.Load()
+}
+
+// Reset sets the total to zero and reports the old value.
+func (b *BytesCounter) Reset() int64 {
+	return b.c.Reset()
+}
+
+// Rate returns the average number of bytes per second since t, as measured
+// by the Clock of the BytesCounter (see WithClock). t is typically the time
+// the counter was created or last reset.
+// It returns 0 if no time has elapsed since t.
+func (b *BytesCounter) Rate(since time.Time) float64 {
+	elapsed := b.c.vs.opts.now().Sub(since)
+	if elapsed <= 0 {
+		return 0
+	}
+	return float64(b.Load()) / elapsed.Seconds()
+}
+
+// RateString is like Rate, but formats the rate like String, followed by
+// "/s", for example "1.4 GiB/s".
+func (b *BytesCounter) RateString(since time.Time) string {
+	return formatBytes(b.Rate(since)) + "/s"
+}
+
+// String formats the total with binary units, for example "512 B" or
+// "1.4 GiB".
+//
+// The result is meant for humans and is not valid JSON, so unlike most
+// counters, a BytesCounter is not an expvar.Var. Publish the total with
+// expvar.Func instead, for example expvar.Func(func() any { return b.Load() }).
+func (b *BytesCounter) String() string {
+	return formatBytes(float64(b.Load()))
+}
+
+// formatBytes formats n with the largest binary unit that keeps its magnitude
+// at least 1, with one decimal place. Counts of less than 1 KiB are formatted
+// as whole bytes.
+func formatBytes(n float64) string {
+	const units = "KMGTPE"
+	abs := n
+	if abs < 0 {
+		abs = -abs
+	}
+	if abs < 1024 {
+		return strconv.FormatFloat(n, 'f', 0, 64) + " B"
+	}
+	i := -1
+	for abs >= 1024 && i < len(units)-1 {
+		abs /= 1024
+		n /= 1024
+		i++
+	}
+	return strconv.FormatFloat(n, 'f', 1, 64) + " " + units[i:i+1] + "iB"
+}
@@ -0,0 +1,53 @@
+package percpu
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestFormatBytes(t *testing.T) {
+	for _, tt := range []struct {
+		n    float64
+		want string
+	}{
+		{0, "0 B"},
+		{1023, "1023 B"},
+		{1024, "1.0 KiB"},
+		{1536, "1.5 KiB"},
+		{1.4 * (1 << 30), "1.4 GiB"},
+		{-2048, "-2.0 KiB"},
+		{1 << 62, "4.0 EiB"},
+	} {
+		if got := formatBytes(tt.n); got != tt.want {
+			t.Errorf("formatBytes(%v) = %q; want %q", tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestBytesCounter(t *testing.T) {
+	clock := &stepClock{}
+	b := NewBytesCounter(WithClock(clock))
+	start := clock.Now()
+	b.Add(3 << 20)
+	b.Add(1 << 20)
+	if got := b.Load(); got != 4<<20 {
+		t.Fatalf("got %d; want %d", got, 4<<20)
+	}
+	if got := fmt.Sprint(b); got != "4.0 MiB" {
+		t.Fatalf("got %q; want 4.0 MiB", got)
+	}
+	// The clock advances by a second on every reading.
+	if got, want := b.Rate(start), float64(4<<20); got != want {
+		t.Fatalf("got rate %v; want %v", got, want)
+	}
+	if got := b.RateString(start); got != "2.0 MiB/s" {
+		t.Fatalf("got %q; want 2.0 MiB/s", got)
+	}
+	if got := b.Rate(clock.now.Add(time.Hour)); got != 0 {
+		t.Fatalf("got rate %v for a time in the future; want 0", got)
+	}
+	if got := b.Reset(); got != 4<<20 {
+		t.Fatalf("Reset returned %d", got)
+	}
+}
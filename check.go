@@ -0,0 +1,54 @@
+//go:build percpucheck
+
+package percpu
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+// checkEnabled reports whether the package is built with the percpucheck
+// build tag.
+const checkEnabled = true
+
+// checkProcID returns a random proc ID for every call, so that goroutines
+// accessing the values returned by Get without synchronization actually share
+// shards and the race detector can notice.
+func checkProcID(shards int) int {
+	// The top-level functions of math/rand do not lock unless rand.Seed was
+	// called.
+	return rand.Intn(shards)
+}
+
+// maxPinned is the longest time the function passed to Pin may run in checked
+// mode. A goroutine that stays pinned longer delays the scheduler and
+// garbage collection for the whole process.
+const maxPinned = 10 * time.Millisecond
+
+// maxBlockedPinned is the time after which watchPinned reports that the
+// function passed to Pin blocks. It is a variable for the tests.
+var maxBlockedPinned = 100 * maxPinned
+
+// pinnedBlocked is called by the timer of watchPinned. It panics on the
+// goroutine of the timer, which crashes the program: the pinned goroutine
+// cannot be interrupted, and it stalls the scheduler and garbage collection
+// for as long as it blocks. It is a variable for the tests.
+var pinnedBlocked = func() {
+	panic(fmt.Sprintf("percpu: function passed to Pin is still running after %v while pinned, it must not block", maxBlockedPinned))
+}
+
+// watchPinned starts a timer that calls pinnedBlocked unless it is stopped
+// before maxBlockedPinned elapses. Pin starts it before pinning the
+// goroutine, as starting a timer may block.
+func watchPinned() *time.Timer {
+	return time.AfterFunc(maxBlockedPinned, pinnedBlocked)
+}
+
+// checkPinned panics if the function passed to Pin ran for d, which is too
+// long. The panic reports the stack of the goroutine that called Pin.
+func checkPinned(d time.Duration) {
+	if d > maxPinned {
+		panic(fmt.Sprintf("percpu: function passed to Pin ran for %v while pinned, the limit is %v", d, maxPinned))
+	}
+}
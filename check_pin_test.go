@@ -0,0 +1,56 @@
+//go:build percpucheck
+
+package percpu
+
+import (
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCheckedPinTooLong(t *testing.T) {
+	defer func() {
+		r, _ := recover().(string)
+		if !strings.Contains(r, "while pinned") {
+			t.Fatalf("got panic %q", r)
+		}
+	}()
+	Pin(func(Proc) {
+		deadline := time.Now().Add(2 * maxPinned)
+		for time.Now().Before(deadline) {
+		}
+	})
+	t.Fatal("Pin did not panic")
+}
+
+func TestCheckedPinBlocked(t *testing.T) {
+	if runtime.GOMAXPROCS(0) < 2 {
+		t.Skip("the watchdog needs another processor to run on")
+	}
+	fired := make(chan struct{})
+	defer func(limit time.Duration, fn func()) {
+		maxBlockedPinned, pinnedBlocked = limit, fn
+	}(maxBlockedPinned, pinnedBlocked)
+	maxBlockedPinned = 2 * maxPinned
+	pinnedBlocked = func() { close(fired) }
+	defer func() {
+		// Pin also panics because fn ran too long.
+		recover()
+		select {
+		case <-fired:
+		default:
+			t.Fatal("the watchdog did not fire while fn was running")
+		}
+	}()
+	Pin(func(Proc) {
+		deadline := time.Now().Add(time.Second)
+		for time.Now().Before(deadline) {
+			select {
+			case <-fired:
+				return
+			default:
+			}
+		}
+	})
+}
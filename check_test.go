@@ -0,0 +1,23 @@
+//go:build percpucheck
+
+package percpu
+
+import (
+	"testing"
+)
+
+func TestCheckedGetSpreadsShards(t *testing.T) {
+	var vs Values[int]
+	first := vs.Get()
+	n := 0
+	vs.Range(func(*int) { n++ })
+	if n < 2 {
+		t.Skip("need at least 2 shards")
+	}
+	for i := 0; i < 1000; i++ {
+		if vs.Get() != first {
+			return
+		}
+	}
+	t.Fatal("Get always returned the same shard in checked mode")
+}
@@ -0,0 +1,22 @@
+package percpu
+
+import (
+	"time"
+)
+
+// A Clock tells the current time.
+//
+// Types in this package that depend on time accept a Clock, so that tests can
+// advance time deterministically (see percputest.FakeClock) instead of
+// sleeping.
+type Clock interface {
+	Now() time.Time
+}
+
+// SystemClock is the Clock that uses time.Now.
+type SystemClock struct{}
+
+// Now returns time.Now().
+func (SystemClock) Now() time.Time {
+	return time.Now()
+}
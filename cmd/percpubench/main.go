@@ -0,0 +1,147 @@
+// Percpubench compares the throughput of percpu.Counter with common
+// alternatives on the current machine.
+//
+// Usage:
+//
+//	percpubench [flags]
+//
+// For every GOMAXPROCS setting in -procs, percpubench measures each counter
+// with every number of goroutines in -goroutines and prints a table. The
+// counters are:
+//
+//	percpu   percpu.Counter
+//	atomic   a single atomic.Int64
+//	mutex    an int64 guarded by a sync.Mutex
+//	striped  -stripes cache-line padded atomic.Int64, picked at random
+//
+// The flags are:
+//
+//	-goroutines list
+//		Comma-separated numbers of goroutines to measure.
+//		Defaults to powers of two up to twice GOMAXPROCS.
+//	-procs list
+//		Comma-separated GOMAXPROCS settings to measure.
+//		Defaults to the current GOMAXPROCS.
+//	-duration d
+//		How long each measurement runs. Defaults to 100ms.
+//	-stripes n
+//		The number of stripes of the striped counter.
+//		Defaults to GOMAXPROCS.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/martin-sucha/percpu"
+	"github.com/martin-sucha/percpu/benchutil"
+	"github.com/martin-sucha/percpu/clrand"
+)
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("percpubench: ")
+	goroutines := flag.String("goroutines", "", "comma-separated `list` of goroutine counts")
+	procs := flag.String("procs", "", "comma-separated `list` of GOMAXPROCS settings")
+	duration := flag.Duration("duration", 100*time.Millisecond, "duration of each measurement")
+	stripes := flag.Int("stripes", 0, "number of stripes of the striped counter, 0 means GOMAXPROCS")
+	flag.Parse()
+	if flag.NArg() > 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	gs, err := parseInts(*goroutines)
+	if err != nil {
+		log.Fatalf("-goroutines: %v", err)
+	}
+	ps, err := parseInts(*procs)
+	if err != nil {
+		log.Fatalf("-procs: %v", err)
+	}
+	if len(ps) == 0 {
+		ps = []int{runtime.GOMAXPROCS(0)}
+	}
+	for i, p := range ps {
+		runtime.GOMAXPROCS(p)
+		if i > 0 {
+			fmt.Println()
+		}
+		fmt.Printf("GOMAXPROCS=%d\n", p)
+		n := *stripes
+		if n <= 0 {
+			n = p
+		}
+		cfg := benchutil.Config{Goroutines: gs, Duration: *duration}
+		rep := benchutil.Compare(cfg, impls(n)...)
+		if _, err := rep.WriteTo(os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
+
+// impls returns the compared counters. percpu.Counter goes first, so that
+// the last column of the report is relative to it.
+func impls(stripes int) []benchutil.Impl {
+	return []benchutil.Impl{
+		{Name: "percpu", New: func() func() {
+			c := percpu.NewCounter()
+			return func() { c.Add(1) }
+		}},
+		{Name: "atomic", New: func() func() {
+			var n atomic.Int64
+			return func() { n.Add(1) }
+		}},
+		{Name: "mutex", New: func() func() {
+			var (
+				mu sync.Mutex
+				n  int64
+			)
+			return func() {
+				mu.Lock()
+				n++
+				mu.Unlock()
+			}
+		}},
+		{Name: "striped", New: func() func() {
+			s := make([]stripe, stripes)
+			return func() { s[clrand.Intn(len(s))].n.Add(1) }
+		}},
+	}
+}
+
+// stripe is an atomic counter followed by a cache line of padding, so that
+// counters of adjacent stripes never share a cache line. Padding by a whole
+// line, instead of the rest of it, also works where CacheLineSize is 0.
+type stripe struct {
+	n atomic.Int64
+	_ [percpu.CacheLineSize]byte
+}
+
+// parseInts parses a comma-separated list of positive integers.
+// An empty string yields an empty list.
+func parseInts(s string) ([]int, error) {
+	if s == "" {
+		return nil, nil
+	}
+	var ns []int
+	for _, f := range strings.Split(s, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(f))
+		if err != nil {
+			return nil, err
+		}
+		if n <= 0 {
+			return nil, fmt.Errorf("%d is not positive", n)
+		}
+		ns = append(ns, n)
+	}
+	return ns, nil
+}
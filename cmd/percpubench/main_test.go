@@ -0,0 +1,37 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/martin-sucha/percpu/benchutil"
+)
+
+func TestParseInts(t *testing.T) {
+	for _, tt := range []struct {
+		in   string
+		want []int
+		err  bool
+	}{
+		{in: "", want: nil},
+		{in: "1", want: []int{1}},
+		{in: "1, 2,8", want: []int{1, 2, 8}},
+		{in: "1,x", err: true},
+		{in: "0", err: true},
+	} {
+		got, err := parseInts(tt.in)
+		if (err != nil) != tt.err || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseInts(%q) = %v, %v", tt.in, got, err)
+		}
+	}
+}
+
+func TestImpls(t *testing.T) {
+	rep := benchutil.Compare(benchutil.Config{Goroutines: []int{2}, Duration: time.Millisecond}, impls(3)...)
+	for _, r := range rep.Results {
+		if r.Ops == 0 {
+			t.Errorf("no operations recorded for %s", r.Impl)
+		}
+	}
+}
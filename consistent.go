@@ -0,0 +1,149 @@
+package percpu
+
+import "sync"
+
+// WithConsistentReads makes Load and Reset of a Counter linearizable with
+// respect to Add: each of them observes all Adds that completed before it
+// started and none that started after it completed, as if the Counter was a
+// single atomic.Int64. Without the option, Load and Reset must tolerate
+// observing some concurrent Adds and missing others, see Counter.
+//
+// The Counter then guards each shard with a mutex, which Add locks for the
+// duration of the update and Load and Reset lock on all shards at once. This
+// makes Add somewhat slower even without contention, and an Add waits while
+// a Load or Reset runs.
+//
+// Types other than Counter ignore the option.
+func WithConsistentReads() Option {
+	return func(o *options) {
+		o.consistent = true
+	}
+}
+
+// readGuard gives the writers of a sharded value shared access and readers
+// exclusive access, like a sync.RWMutex with its reader and writer roles
+// swapped. The writers only lock the mutex of their own shard, so they
+// do not contend with each other.
+//
+// The mutexes are stored in a Flat, so their number is fixed and a reader
+// that locked all of them excludes all writers, even ones that are about to
+// use a shard of the guarded Values that was not allocated yet.
+type readGuard struct {
+	locks *Flat[sync.Mutex]
+}
+
+func newReadGuard(opts []Option) readGuard {
+	return readGuard{locks: NewFlat[sync.Mutex](opts...)}
+}
+
+// lockShard locks the mutex of the calling goroutine's shard for an update
+// and returns it. A nil guard returns nil.
+func (g readGuard) lockShard() *sync.Mutex {
+	if g.locks == nil {
+		return nil
+	}
+	m := g.locks.Get()
+	m.Lock()
+	return m
+}
+
+// lockProc is like lockShard for a goroutine pinned to p by Pin. It only
+// waits for the mutex with the goroutine unpinned.
+func (g readGuard) lockProc(p Proc) *sync.Mutex {
+	if g.locks == nil {
+		return nil
+	}
+	shards := g.locks.shards
+	m := &shards[g.locks.opts.mapProcID(p.id)%len(shards)].v
+	if !m.TryLock() {
+		procUnpin()
+		m.Lock()
+		procPin()
+	}
+	return m
+}
+
+// lockAll excludes all updates until unlockAll is called.
+// The mutexes are locked in the order of their index, so concurrent calls do
+// not deadlock.
+func (g readGuard) lockAll() {
+	if g.locks != nil {
+		g.locks.Range((*sync.Mutex).Lock)
+	}
+}
+
+func (g readGuard) unlockAll() {
+	if g.locks != nil {
+		g.locks.Range((*sync.Mutex).Unlock)
+	}
+}
+
+// A SnapshotGroup holds related Counters that can be read together
+// consistently, for example hits, misses and their total, so that ratios of
+// the exported values are not skewed by Adds running concurrently to the
+// export.
+//
+// The Counters of a group share a guard like the one of WithConsistentReads:
+// Load and Reset of the group exclude Adds to all of its Counters at once,
+// as if the Counters were updated and read under a single lock. Adds to
+// different Counters still only lock the shard of the calling goroutine.
+//
+// A SnapshotGroup must be created with NewSnapshotGroup.
+type SnapshotGroup struct {
+	guard readGuard
+
+	mu       sync.Mutex
+	names    []string
+	counters []*Counter
+}
+
+// NewSnapshotGroup returns an empty SnapshotGroup. The options configure the
+// sharding of the guard shared by the Counters.
+func NewSnapshotGroup(opts ...Option) *SnapshotGroup {
+	return &SnapshotGroup{guard: newReadGuard(opts)}
+}
+
+// NewCounter returns a fresh Counter that belongs to g, named name.
+// The options configure the sharding of the Counter. Load and Reset of the
+// Counter itself are consistent like with WithConsistentReads.
+// It panics if name is already used in g.
+func (g *SnapshotGroup) NewCounter(name string, opts ...Option) *Counter {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for _, n := range g.names {
+		if n == name {
+			panic("percpu: counter " + name + " is already in the group")
+		}
+	}
+	c := &Counter{guard: g.guard}
+	c.vs.opts.apply(opts)
+	g.names = append(g.names, name)
+	g.counters = append(g.counters, c)
+	return c
+}
+
+// Load returns the totals of all Counters of g, keyed by name. The totals
+// are consistent with each other: an Add to any of them is either reflected
+// in the result or not, and all Adds that completed before Load was called
+// are.
+func (g *SnapshotGroup) Load() map[string]int64 {
+	return g.read((*Counter).load)
+}
+
+// Reset sets all Counters of g to zero and reports their old totals, keyed by
+// name. Like with Load, the totals are consistent with each other.
+func (g *SnapshotGroup) Reset() map[string]int64 {
+	return g.read((*Counter).reset)
+}
+
+func (g *SnapshotGroup) read(fn func(c *Counter) int64) map[string]int64 {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	totals := make(map[string]int64, len(g.counters))
+	g.guard.lockAll()
+	for i, c := range g.counters {
+		totals[g.names[i]] = fn(c)
+	}
+	g.guard.unlockAll()
+	return totals
+}
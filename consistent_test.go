@@ -0,0 +1,140 @@
+package percpu
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestConsistentReads(t *testing.T) {
+	c := NewCounter(WithConsistentReads())
+	var wg sync.WaitGroup
+	var stop atomic.Bool
+	// Each writer adds 1 and subtracts it again right away, so that
+	// a consistent read observes a total between 0 and the number of
+	// writers, and never a negative one.
+	const writers = 8
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for !stop.Load() {
+				c.Add(1)
+				c.Add(-1)
+			}
+		}()
+	}
+	for i := 0; i < 1000; i++ {
+		if n := c.Load(); n < 0 || n > writers {
+			stop.Store(true)
+			wg.Wait()
+			t.Fatalf("inconsistent Load: %d", n)
+		}
+		if s := c.Snapshot().Total(); s < 0 || s > writers {
+			stop.Store(true)
+			wg.Wait()
+			t.Fatalf("inconsistent Snapshot: %d", s)
+		}
+	}
+	stop.Store(true)
+	wg.Wait()
+	if n := c.Load(); n != 0 {
+		t.Fatalf("got %d after writers finished; want 0", n)
+	}
+}
+
+func TestConsistentReadsReset(t *testing.T) {
+	c := NewCounter(WithConsistentReads())
+	dst := NewCounter()
+	c.Add(5)
+	c.Store(3)
+	if got := c.Load(); got != 3 {
+		t.Fatalf("got %d after Store; want 3", got)
+	}
+	if got := c.TransferTo(dst); got != 3 {
+		t.Fatalf("TransferTo moved %d; want 3", got)
+	}
+	if got := dst.Load(); got != 3 {
+		t.Fatalf("got %d in dst; want 3", got)
+	}
+	Pin(func(p Proc) { c.AddProc(p, 2) })
+	if got := c.Reset(); got != 2 {
+		t.Fatalf("Reset returned %d; want 2", got)
+	}
+}
+
+func TestConsistentReadsAddProcWaits(t *testing.T) {
+	c := NewCounter(WithConsistentReads())
+	c.guard.lockAll()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		// Waiting for the lock would crash the runtime if AddProc
+		// did it while pinned.
+		Pin(func(p Proc) { c.AddProc(p, 1) })
+	}()
+	time.Sleep(time.Millisecond)
+	if got := c.vs.Get().Load(); got != 0 {
+		t.Fatalf("AddProc added %d while the Counter was locked", got)
+	}
+	c.guard.unlockAll()
+	<-done
+	if got := c.Load(); got != 1 {
+		t.Fatalf("got %d; want 1", got)
+	}
+}
+
+func TestSnapshotGroup(t *testing.T) {
+	g := NewSnapshotGroup()
+	hits := g.NewCounter("hits")
+	misses := g.NewCounter("misses")
+	total := g.NewCounter("total")
+	var wg sync.WaitGroup
+	var stop atomic.Bool
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; !stop.Load(); j++ {
+				if j%3 == 0 {
+					misses.Add(1)
+				} else {
+					hits.Add(1)
+				}
+				total.Add(1)
+			}
+		}()
+	}
+	defer func() {
+		stop.Store(true)
+		wg.Wait()
+	}()
+	for i := 0; i < 1000 || hits.Load() == 0; i++ {
+		s := g.Load()
+		// Each request adds to hits or misses before it adds to total.
+		if s["total"] > s["hits"]+s["misses"] {
+			t.Fatalf("inconsistent totals: %v", s)
+		}
+	}
+	stop.Store(true)
+	wg.Wait()
+	s := g.Reset()
+	if s["total"] != s["hits"]+s["misses"] {
+		t.Fatalf("got totals %v", s)
+	}
+	if s := g.Load(); s["hits"] != 0 || s["misses"] != 0 || s["total"] != 0 {
+		t.Fatalf("got %v after Reset", s)
+	}
+}
+
+func TestSnapshotGroupDuplicateName(t *testing.T) {
+	g := NewSnapshotGroup()
+	g.NewCounter("x")
+	defer func() {
+		if recover() == nil {
+			t.Fatal("no panic for a duplicate name")
+		}
+	}()
+	g.NewCounter("x")
+}
@@ -2,6 +2,7 @@ package percpu
 
 import (
 	"sync/atomic"
+	"time"
 )
 
 // A Counter is an int64 counter which may be efficiently incremented
@@ -25,22 +26,87 @@ import (
 // The value of t0 may be any of 0, 1, 2, or 3.
 // The value of t1 may be any of 0, 1, 2, or 3 as well.
 // However, t0+t1 must equal 3.
+//
+// Counters created with the WithConsistentReads option give stronger
+// guarantees, at the cost of slower Adds.
 type Counter struct {
 	vs Values[atomic.Int64]
+	// guard is only set with WithConsistentReads.
+	guard readGuard
 }
 
 // NewCounter returns a fresh Counter initialized to zero.
-func NewCounter() *Counter {
-	return &Counter{}
+// The options configure the sharding of the Counter.
+func NewCounter(opts ...Option) *Counter {
+	c := &Counter{}
+	c.vs.opts.apply(opts)
+	if c.vs.opts.consistent {
+		c.guard = newReadGuard(opts)
+	}
+	return c
 }
 
 // Add adds n to the total count.
 func (c *Counter) Add(n int64) {
+	if m := c.guard.lockShard(); m != nil {
+		c.vs.Get().Add(n)
+		m.Unlock()
+		return
+	}
+	c.vs.Get().Add(n)
+}
+
+// Inc adds 1 to the total count.
+func (c *Counter) Inc() {
+	c.Add(1)
+}
+
+// Dec subtracts 1 from the total count.
+func (c *Counter) Dec() {
+	c.Add(-1)
+}
+
+// Sub subtracts n from the total count.
+func (c *Counter) Sub(n int64) {
+	c.Add(-n)
+}
+
+// Store sets the total count to n. It resets every shard and then adds n to
+// the shard of the calling goroutine.
+//
+// Unlike the Store method of atomic.Int64, Store is not atomic with respect to
+// concurrent Add calls: an Add that happens concurrently to Store is either
+// discarded by it or added to n. A concurrent Load may observe a total that
+// is neither the old value nor n, for example zero. With WithConsistentReads,
+// Store is atomic.
+func (c *Counter) Store(n int64) {
+	c.guard.lockAll()
+	c.reset()
 	c.vs.Get().Add(n)
+	c.guard.unlockAll()
+}
+
+// AddSince adds the number of nanoseconds elapsed since t, as measured by the
+// Clock of the Counter (see WithClock).
+func (c *Counter) AddSince(t time.Time) {
+	c.Add(int64(c.vs.opts.now().Sub(t)))
+}
+
+// Time runs fn and adds its duration in nanoseconds.
+func (c *Counter) Time(fn func()) {
+	start := c.vs.opts.now()
+	fn()
+	c.AddSince(start)
 }
 
 // Load computes the total counter value.
 func (c *Counter) Load() int64 {
+	c.guard.lockAll()
+	defer c.guard.unlockAll()
+	return c.load()
+}
+
+func (c *Counter) load() int64 {
 	var sum int64
 	c.vs.Range(func(v *atomic.Int64) {
 		sum += v.Load()
@@ -50,9 +116,55 @@ func (c *Counter) Load() int64 {
 
 // Reset sets the counter to zero and reports the old value.
 func (c *Counter) Reset() int64 {
+	c.guard.lockAll()
+	defer c.guard.unlockAll()
+	return c.reset()
+}
+
+func (c *Counter) reset() int64 {
 	var sum int64
 	c.vs.Range(func(v *atomic.Int64) {
 		sum += v.Swap(0)
 	})
 	return sum
 }
+
+// Imbalance reports how evenly the total count is spread across shards.
+// It is useful to check that concurrent Add calls are actually served by
+// different shards.
+//
+// Like Load, Imbalance does not observe a consistent view of the shards if it
+// is called concurrently to Add or Reset.
+func (c *Counter) Imbalance() Imbalance {
+	return MeasureImbalance(&c.vs, func(v *atomic.Int64) float64 {
+		return float64(v.Load())
+	})
+}
+
+// TransferTo moves the current count of c to dst and reports the moved
+// amount. The sum of c and dst is preserved.
+//
+// Each shard of c is reset and its value added to dst immediately, so
+// a concurrent Load of dst misses at most the value of one shard of c, not
+// the whole count as with a separate Reset and Add.
+// Adds to c that happen concurrently to TransferTo are either moved or stay
+// in c.
+//
+// If c was created with WithConsistentReads, TransferTo resets all shards of
+// c at once and adds the total to dst afterwards. A concurrent Load of dst may
+// then miss the whole moved amount.
+func (c *Counter) TransferTo(dst *Counter) int64 {
+	if c.guard.locks != nil {
+		n := c.Reset()
+		dst.Add(n)
+		return n
+	}
+	var sum int64
+	c.vs.Range(func(v *atomic.Int64) {
+		if n := v.Swap(0); n != 0 {
+			dst.Add(n)
+			sum += n
+		}
+	})
+	return sum
+}
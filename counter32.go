@@ -0,0 +1,58 @@
+package percpu
+
+import (
+	"strconv"
+	"sync/atomic"
+)
+
+// A Counter32 is an int32 counter which may be efficiently incremented
+// by many goroutines concurrently. It uses 32-bit atomic operations, which are
+// cheaper than the 64-bit ones used by Counter on some 32-bit platforms.
+// The total wraps around on overflow.
+//
+// The shards of a Counter32 are still padded to a cache line each, so it
+// saves little memory compared to Counter. Use WithMaxShards to reduce the
+// footprint on machines with many processors.
+//
+// Like Counter, Load and Reset do not observe a consistent view of the total
+// if they are called concurrently to Add. See Counter for the guarantees.
+type Counter32 struct {
+	vs Values[atomic.Int32]
+}
+
+// NewCounter32 returns a fresh Counter32 initialized to zero.
+// The options configure the sharding of the Counter32.
+func NewCounter32(opts ...Option) *Counter32 {
+	c := &Counter32{}
+	c.vs.opts.apply(opts)
+	return c
+}
+
+// Add adds n to the total count.
+func (c *Counter32) Add(n int32) {
+	c.vs.Get().Add(n)
+}
+
+// Load computes the total counter value.
+func (c *Counter32) Load() int32 {
+	var sum int32
+	c.vs.Range(func(v *atomic.Int32) {
+		sum += v.Load()
+	})
+	return sum
+}
+
+// Reset sets the counter to zero and reports the old value.
+func (c *Counter32) Reset() int32 {
+	var sum int32
+	c.vs.Range(func(v *atomic.Int32) {
+		sum += v.Swap(0)
+	})
+	return sum
+}
+
+// String returns the total count in decimal, so that Counter32 implements
+// expvar.Var.
+func (c *Counter32) String() string {
+	return strconv.FormatInt(int64(c.Load()), 10)
+}
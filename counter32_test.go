@@ -0,0 +1,41 @@
+package percpu
+
+import (
+	"math"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestCounter32(t *testing.T) {
+	c := NewCounter32()
+	var wg sync.WaitGroup
+	const n = 100
+	var resetSum atomic.Int32
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < n; i++ {
+				c.Add(1)
+				if i%20 == 0 {
+					resetSum.Add(c.Reset())
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if got, want := resetSum.Load()+c.Load(), int32(n*n); got != want {
+		t.Fatalf("got total %d; want %d", got, want)
+	}
+}
+
+func TestCounter32Wraps(t *testing.T) {
+	setProcIDs(t, 2, 0, 1)
+	c := NewCounter32()
+	c.Add(math.MaxInt32)
+	c.Add(1)
+	if got := c.Load(); got != math.MinInt32 {
+		t.Fatalf("got %d; want %d", got, math.MinInt32)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"sync"
 	"sync/atomic"
 	"testing"
+	"time"
 )
 
 func TestCounter(t *testing.T) {
@@ -116,3 +117,75 @@ func BenchmarkCounterParallel(b *testing.B) {
 		})
 	}
 }
+
+func TestCounterTransferTo(t *testing.T) {
+	src := NewCounter()
+	dst := NewCounter()
+	dst.Add(5)
+	var wg sync.WaitGroup
+	const n = 100
+	var moved int64
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < n; i++ {
+				src.Add(1)
+				if i%20 == 0 {
+					atomic.AddInt64(&moved, src.TransferTo(dst))
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	moved += src.TransferTo(dst)
+	if got := src.Load(); got != 0 {
+		t.Fatalf("after TransferTo, src was %d", got)
+	}
+	if want := int64(n * n); moved != want {
+		t.Fatalf("moved %d in total; want %d", moved, want)
+	}
+	if got, want := dst.Load(), int64(n*n+5); got != want {
+		t.Fatalf("got dst %d; want %d", got, want)
+	}
+}
+
+func TestCounterTime(t *testing.T) {
+	clock := &stepClock{}
+	c := NewCounter(WithClock(clock))
+	c.AddSince(clock.now)
+	if got, want := c.Load(), int64(time.Second); got != want {
+		t.Fatalf("AddSince: got %d; want %d", got, want)
+	}
+	ran := false
+	c.Time(func() { ran = true })
+	if !ran {
+		t.Fatal("Time did not run fn")
+	}
+	if got, want := c.Load(), int64(2*time.Second); got != want {
+		t.Fatalf("Time: got %d; want %d", got, want)
+	}
+}
+
+func TestCounterConvenience(t *testing.T) {
+	setProcIDs(t, 4, 0, 1, 2, 3, 1, 3)
+	c := NewCounter()
+	c.Inc()
+	c.Inc()
+	c.Dec()
+	c.Sub(5)
+	if got := c.Load(); got != -4 {
+		t.Fatalf("got %d; want -4", got)
+	}
+	c.Store(7)
+	if got := c.Load(); got != 7 {
+		t.Fatalf("got %d after Store; want 7", got)
+	}
+	if got := c.vs.Shard(1).Load(); got != 7 {
+		t.Fatalf("got %d in shard 1; want Store to deposit 7 there", got)
+	}
+	c.Add(1)
+	if got := c.Load(); got != 8 {
+		t.Fatalf("got %d; want 8", got)
+	}
+}
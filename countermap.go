@@ -0,0 +1,82 @@
+package percpu
+
+import "sync"
+
+// A CounterMap is a set of int64 counters keyed by K, like events per tenant,
+// which may be efficiently incremented by many goroutines concurrently.
+// Unlike CounterVec, the set of keys does not need to be small: each shard
+// holds a map with the counts of the keys updated on that shard, and the
+// counts are merged when they are read.
+//
+// Each shard is guarded by a mutex, which is only contended by goroutines
+// that use the same shard. Reads lock the shards one by one, so like with
+// Counter, Load, Range and Reset do not observe a consistent view of the
+// counts if they are called concurrently to Add.
+//
+// A CounterMap must be created with NewCounterMap.
+type CounterMap[K comparable] struct {
+	vs *Values[counterMapShard[K]]
+}
+
+type counterMapShard[K comparable] struct {
+	mu sync.Mutex
+	m  map[K]int64
+}
+
+// NewCounterMap returns an empty CounterMap.
+// The options configure the sharding of the CounterMap.
+func NewCounterMap[K comparable](opts ...Option) *CounterMap[K] {
+	return &CounterMap[K]{
+		vs: NewValuesFunc(func(int) counterMapShard[K] {
+			return counterMapShard[K]{m: make(map[K]int64)}
+		}, opts...),
+	}
+}
+
+// Add adds n to the count of key.
+func (c *CounterMap[K]) Add(key K, n int64) {
+	s := c.vs.Get()
+	s.mu.Lock()
+	s.m[key] += n
+	s.mu.Unlock()
+}
+
+// Load returns the count of key, or zero if it was never added to.
+func (c *CounterMap[K]) Load(key K) int64 {
+	var sum int64
+	c.vs.Range(func(s *counterMapShard[K]) {
+		s.mu.Lock()
+		sum += s.m[key]
+		s.mu.Unlock()
+	})
+	return sum
+}
+
+// Range calls fn with the count of every key that was added to, in no
+// particular order. The counts are merged before fn is first called, so fn
+// may use c.
+func (c *CounterMap[K]) Range(fn func(key K, n int64)) {
+	for k, n := range c.merge(false) {
+		fn(k, n)
+	}
+}
+
+// Reset removes all keys and reports their counts.
+func (c *CounterMap[K]) Reset() map[K]int64 {
+	return c.merge(true)
+}
+
+func (c *CounterMap[K]) merge(reset bool) map[K]int64 {
+	total := make(map[K]int64)
+	c.vs.Range(func(s *counterMapShard[K]) {
+		s.mu.Lock()
+		for k, n := range s.m {
+			total[k] += n
+		}
+		if reset {
+			s.m = make(map[K]int64)
+		}
+		s.mu.Unlock()
+	})
+	return total
+}
@@ -0,0 +1,57 @@
+package percpu
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestCounterMap(t *testing.T) {
+	c := NewCounterMap[string]()
+	var wg sync.WaitGroup
+	tenants := []string{"a", "b", "c"}
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 300; j++ {
+				c.Add(tenants[j%3], int64(j%3+1))
+			}
+		}()
+	}
+	wg.Wait()
+	if got := c.Load("b"); got != 2000 {
+		t.Fatalf("got %d for b; want 2000", got)
+	}
+	if got := c.Load("missing"); got != 0 {
+		t.Fatalf("got %d for a missing key; want 0", got)
+	}
+	got := make(map[string]int64)
+	c.Range(func(key string, n int64) {
+		got[key] = n
+	})
+	if len(got) != 3 || got["a"] != 1000 || got["b"] != 2000 || got["c"] != 3000 {
+		t.Fatalf("got %v", got)
+	}
+	if r := c.Reset(); len(r) != 3 || r["c"] != 3000 {
+		t.Fatalf("Reset returned %v", r)
+	}
+	n := 0
+	c.Range(func(string, int64) { n++ })
+	if n != 0 {
+		t.Fatalf("got %d keys after Reset; want 0", n)
+	}
+}
+
+func TestCounterMapShards(t *testing.T) {
+	setProcIDs(t, 2, 0, 1, 1)
+	c := NewCounterMap[int]()
+	c.Add(7, 1)
+	c.Add(7, 2)
+	c.Add(8, 4)
+	if got := c.vs.Shard(1).m[7]; got != 2 {
+		t.Fatalf("got %d for 7 in shard 1; want 2", got)
+	}
+	if got := c.Load(7); got != 3 {
+		t.Fatalf("got %d for 7; want 3", got)
+	}
+}
@@ -0,0 +1,119 @@
+package percpu
+
+import "sync/atomic"
+
+// A CounterSet is a fixed set of related int64 counters, like cache hits,
+// misses and evictions, which may be efficiently incremented by many
+// goroutines concurrently.
+//
+// Unlike separate Counters, the counters of a set share their shards: each
+// shard holds one int64 for each counter, padded as a whole. This saves the
+// padding of all but one counter, and updating several counters of the set
+// needs only one shard lookup, see Local. The counters of one shard share
+// cache lines, so the set is meant for counters that are updated by the same
+// goroutines anyway.
+//
+// The counters are addressed by their index in the names passed to
+// NewCounterSet. Index resolves a name to the index.
+//
+// Like Counter, Load and Reset do not observe a consistent view of the totals
+// if they are called concurrently to Add. See Counter for the guarantees.
+type CounterSet struct {
+	names []string
+	vs    *Values[[]atomic.Int64]
+}
+
+// NewCounterSet returns a fresh CounterSet with one counter for each of names,
+// initialized to zero. The options configure the sharding of the CounterSet.
+// It panics if names is empty or contains duplicates.
+func NewCounterSet(names []string, opts ...Option) *CounterSet {
+	if len(names) == 0 {
+		panic("percpu: NewCounterSet without counters")
+	}
+	for i, name := range names {
+		for _, other := range names[:i] {
+			if name == other {
+				panic("percpu: counter " + name + " is already in the set")
+			}
+		}
+	}
+	n := len(names)
+	return &CounterSet{
+		names: append([]string(nil), names...),
+		vs: NewValuesFunc(func(int) []atomic.Int64 {
+			return paddedSlice[atomic.Int64](n)
+		}, opts...),
+	}
+}
+
+// Names returns the names of the counters in s, by index.
+func (s *CounterSet) Names() []string {
+	return append([]string(nil), s.names...)
+}
+
+// Index returns the index of the counter named name, or -1 if there is none.
+func (s *CounterSet) Index(name string) int {
+	for i, n := range s.names {
+		if n == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// Add adds n to the counter with index i.
+func (s *CounterSet) Add(i int, n int64) {
+	(*s.vs.Get())[i].Add(n)
+}
+
+// A CounterSetShard is the shard of a CounterSet used by a goroutine, see
+// CounterSet.Local.
+type CounterSetShard struct {
+	v []atomic.Int64
+}
+
+// Local returns the shard of s the calling goroutine should use, so that it
+// can update several counters of s with one shard lookup. Like the pointer
+// returned by Values.Get, the shard may be used after the goroutine migrated
+// to another processor, so it should only be used for a few updates in a row
+// and then discarded.
+func (s *CounterSet) Local() CounterSetShard {
+	return CounterSetShard{v: *s.vs.Get()}
+}
+
+// Add adds n to the counter with index i.
+func (l CounterSetShard) Add(i int, n int64) {
+	l.v[i].Add(n)
+}
+
+// Load computes the total of the counter with index i.
+func (s *CounterSet) Load(i int) int64 {
+	_ = s.names[i] // panic on an invalid index even if no shard was used
+	var sum int64
+	s.vs.Range(func(v *[]atomic.Int64) {
+		sum += (*v)[i].Load()
+	})
+	return sum
+}
+
+// LoadAll computes the totals of all counters, by index.
+func (s *CounterSet) LoadAll() []int64 {
+	sums := make([]int64, len(s.names))
+	s.vs.Range(func(v *[]atomic.Int64) {
+		for i := range sums {
+			sums[i] += (*v)[i].Load()
+		}
+	})
+	return sums
+}
+
+// Reset sets all counters to zero and reports their old totals, by index.
+func (s *CounterSet) Reset() []int64 {
+	sums := make([]int64, len(s.names))
+	s.vs.Range(func(v *[]atomic.Int64) {
+		for i := range sums {
+			sums[i] += (*v)[i].Swap(0)
+		}
+	})
+	return sums
+}
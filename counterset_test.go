@@ -0,0 +1,66 @@
+package percpu
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestCounterSet(t *testing.T) {
+	s := NewCounterSet([]string{"hits", "misses", "evictions"})
+	hits, misses := s.Index("hits"), s.Index("misses")
+	if hits != 0 || misses != 1 || s.Index("other") != -1 {
+		t.Fatalf("got indexes %d, %d, %d", hits, misses, s.Index("other"))
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				l := s.Local()
+				l.Add(hits, 1)
+				l.Add(misses, 2)
+				s.Add(2, 3)
+			}
+		}()
+	}
+	wg.Wait()
+	if got := s.Load(misses); got != 2000 {
+		t.Fatalf("got %d misses; want 2000", got)
+	}
+	if got, want := s.LoadAll(), []int64{1000, 2000, 3000}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+	if got, want := s.Reset(), []int64{1000, 2000, 3000}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("Reset returned %v; want %v", got, want)
+	}
+	if got, want := s.LoadAll(), []int64{0, 0, 0}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v after Reset; want %v", got, want)
+	}
+}
+
+func TestCounterSetShards(t *testing.T) {
+	setProcIDs(t, 2, 0, 1)
+	s := NewCounterSet([]string{"a", "b"})
+	s.Add(0, 1)
+	s.Local().Add(1, 2)
+	if got := (*s.vs.Shard(0))[0].Load(); got != 1 {
+		t.Fatalf("got %d in shard 0; want 1", got)
+	}
+	if got := (*s.vs.Shard(1))[1].Load(); got != 2 {
+		t.Fatalf("got %d in shard 1; want 2", got)
+	}
+	if got := s.Names(); !reflect.DeepEqual(got, []string{"a", "b"}) {
+		t.Fatalf("got names %v", got)
+	}
+}
+
+func TestCounterSetDuplicateName(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("no panic for a duplicate name")
+		}
+	}()
+	NewCounterSet([]string{"a", "b", "a"})
+}
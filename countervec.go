@@ -0,0 +1,126 @@
+package percpu
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// A CounterVec is a set of Counters keyed by label values, like the method and
+// status code of HTTP requests. The Counter for a combination of label values
+// is created when it is first used.
+//
+// Looking up an existing Counter does not write to memory shared with other
+// goroutines, so With scales like Counter.Add. Still, callers that update the
+// same Counter often should keep the result of With instead of looking it up
+// every time.
+type CounterVec struct {
+	labels []string
+	opts   []Option
+	// counters maps the keys of the label values to *counterVecEntry.
+	counters sync.Map
+}
+
+type counterVecEntry struct {
+	values []string
+	c      *Counter
+}
+
+// counterVecKey returns the key of the label values in the counters of
+// a CounterVec. Every value is prefixed with its length, so that no two
+// different lists of values have the same key, whatever bytes they contain.
+func counterVecKey(values []string) string {
+	var b strings.Builder
+	for _, s := range values {
+		b.WriteString(strconv.Itoa(len(s)))
+		b.WriteByte(':')
+		b.WriteString(s)
+	}
+	return b.String()
+}
+
+// NewCounterVec returns an empty CounterVec with the given label names.
+// The options configure the sharding of its Counters.
+func NewCounterVec(labels []string, opts ...Option) *CounterVec {
+	return &CounterVec{
+		labels: append([]string(nil), labels...),
+		opts:   opts,
+	}
+}
+
+// Labels returns the label names of v.
+func (v *CounterVec) Labels() []string {
+	return append([]string(nil), v.labels...)
+}
+
+// With returns the Counter for the given label values, in the order of the
+// label names of v, creating it if necessary.
+// It panics if the number of values does not match the number of labels.
+func (v *CounterVec) With(values ...string) *Counter {
+	if len(values) != len(v.labels) {
+		panic("percpu: CounterVec.With with wrong number of label values")
+	}
+	key := counterVecKey(values)
+	if e, ok := v.counters.Load(key); ok {
+		return e.(*counterVecEntry).c
+	}
+	e, _ := v.counters.LoadOrStore(key, &counterVecEntry{
+		values: append([]string(nil), values...),
+		c:      NewCounter(v.opts...),
+	})
+	return e.(*counterVecEntry).c
+}
+
+// Each calls fn for every Counter of v with its label values, in the order of
+// the label values. fn must not modify values.
+func (v *CounterVec) Each(fn func(values []string, c *Counter)) {
+	var entries []*counterVecEntry
+	v.counters.Range(func(_, e any) bool {
+		entries = append(entries, e.(*counterVecEntry))
+		return true
+	})
+	sort.Slice(entries, func(i, j int) bool {
+		a, b := entries[i].values, entries[j].values
+		for k := range a {
+			if a[k] != b[k] {
+				return a[k] < b[k]
+			}
+		}
+		return false
+	})
+	for _, e := range entries {
+		fn(e.values, e.c)
+	}
+}
+
+// A CounterVecSample is the total of one Counter of a CounterVec.
+type CounterVecSample struct {
+	// Values are the label values of the Counter, in the order of the label
+	// names of the CounterVec.
+	Values []string
+	// Total is the value of the Counter.
+	Total int64
+}
+
+// Collect returns the label values and the total of every Counter of v, in
+// the order of the label values. Like Each, it does not observe a consistent
+// view of the Counters if they are updated concurrently.
+func (v *CounterVec) Collect() []CounterVecSample {
+	var samples []CounterVecSample
+	v.Each(func(values []string, c *Counter) {
+		samples = append(samples, CounterVecSample{
+			Values: append([]string(nil), values...),
+			Total:  c.Load(),
+		})
+	})
+	return samples
+}
+
+// Delete removes the Counter for the given label values from v and reports
+// whether it was present. Counters returned by With before stay usable, but
+// are no longer part of v.
+func (v *CounterVec) Delete(values ...string) bool {
+	_, ok := v.counters.LoadAndDelete(counterVecKey(values))
+	return ok
+}
@@ -0,0 +1,88 @@
+package percpu
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestCounterVec(t *testing.T) {
+	v := NewCounterVec([]string{"method", "code"})
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				v.With("GET", "200").Add(1)
+				if j%10 == 0 {
+					v.With("POST", "500").Inc()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	v.With("GET", "404").Add(3)
+
+	var got []string
+	v.Each(func(values []string, c *Counter) {
+		got = append(got, fmt.Sprint(values, c.Load()))
+	})
+	want := []string{"[GET 200] 1000", "[GET 404] 3", "[POST 500] 100"}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+	if v.With("GET", "200") != v.With("GET", "200") {
+		t.Fatal("With returned different Counters for the same labels")
+	}
+	if !v.Delete("GET", "404") || v.Delete("GET", "404") {
+		t.Fatal("Delete did not report the presence of the Counter")
+	}
+	if got := v.With("GET", "404").Load(); got != 0 {
+		t.Fatalf("got %d after Delete; want a fresh Counter", got)
+	}
+}
+
+func TestCounterVecCollect(t *testing.T) {
+	v := NewCounterVec([]string{"method", "code"})
+	if got := v.Collect(); len(got) != 0 {
+		t.Fatalf("got %v for an empty CounterVec", got)
+	}
+	v.With("POST", "500").Add(2)
+	v.With("GET", "200").Add(5)
+	got := v.Collect()
+	want := []CounterVecSample{
+		{Values: []string{"GET", "200"}, Total: 5},
+		{Values: []string{"POST", "500"}, Total: 2},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+	got[0].Values[0] = "PUT"
+	if v.Collect()[0].Values[0] != "GET" {
+		t.Fatal("modifying the result of Collect changed the CounterVec")
+	}
+}
+
+func TestCounterVecLabelValues(t *testing.T) {
+	v := NewCounterVec([]string{"a", "b"})
+	// The values must not be confused by joining them.
+	v.With("x", "y z").Add(1)
+	v.With("x y", "z").Add(2)
+	if got := v.With("x", "y z").Load(); got != 1 {
+		t.Fatalf("got %d; want 1", got)
+	}
+	// Not even with bytes that are not valid UTF-8.
+	v.With("x\xff", "y").Add(4)
+	v.With("x", "\xffy").Add(8)
+	if got := v.With("x\xff", "y").Load(); got != 4 {
+		t.Fatalf("got %d; want 4", got)
+	}
+	defer func() {
+		if recover() == nil {
+			t.Fatal("no panic for a wrong number of label values")
+		}
+	}()
+	v.With("x")
+}
@@ -0,0 +1,74 @@
+//go:build !purego
+
+package percpu
+
+import (
+	"runtime"
+)
+
+// The instructions below read the IA32_TSC_AUX register, where Linux stores
+// the number of the CPU in the low 12 bits and the NUMA node above them.
+
+// cpuid executes the CPUID instruction.
+func cpuid(eaxArg, ecxArg uint32) (eax, ebx, ecx, edx uint32)
+
+// rdpid executes the RDPID instruction and returns IA32_TSC_AUX.
+func rdpid() uint32
+
+// rdtscp executes the RDTSCP instruction and returns IA32_TSC_AUX.
+func rdtscp() uint32
+
+// tscAux selects how fastCPUID reads IA32_TSC_AUX, or is nil if the fast
+// path is not available.
+var tscAux func() uint32
+
+func init() {
+	maxID, _, _, _ := cpuid(0, 0)
+	maxExtID, _, _, _ := cpuid(0x80000000, 0)
+	var features7ECX, extFeaturesEDX uint32
+	if maxID >= 7 {
+		_, _, features7ECX, _ = cpuid(7, 0)
+	}
+	if maxExtID >= 0x80000001 {
+		_, _, _, extFeaturesEDX = cpuid(0x80000001, 0)
+	}
+	switch {
+	case features7ECX&(1<<22) != 0:
+		tscAux = rdpid
+	case extFeaturesEDX&(1<<27) != 0:
+		tscAux = rdtscp
+	default:
+		return
+	}
+	// Hypervisors can hide the register from the kernel, so make sure that
+	// it agrees with the system call.
+	if !tscAuxAgrees() {
+		tscAux = nil
+	}
+}
+
+// tscAuxAgrees reports whether tscAux returns the CPU number reported by the
+// getcpu system call. The thread can migrate to another CPU between the
+// reads, so a comparison is only conclusive if tscAux returns the same value
+// before and after the system call; otherwise it is retried.
+func tscAuxAgrees() bool {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	for i := 0; i < 10; i++ {
+		before := tscAux() & 0xfff
+		cpu := getcpu()
+		if after := tscAux() & 0xfff; after == before {
+			return int(before) == cpu
+		}
+	}
+	return false
+}
+
+// fastCPUID returns the number of the CPU the calling thread runs on using
+// a single instruction, if the CPU supports it.
+func fastCPUID() (int, bool) {
+	if tscAux == nil {
+		return 0, false
+	}
+	return int(tscAux() & 0xfff), true
+}
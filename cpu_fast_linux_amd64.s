@@ -0,0 +1,27 @@
+//go:build !purego
+
+#include "textflag.h"
+
+// func cpuid(eaxArg, ecxArg uint32) (eax, ebx, ecx, edx uint32)
+TEXT ·cpuid(SB), NOSPLIT, $0-24
+	MOVL eaxArg+0(FP), AX
+	MOVL ecxArg+4(FP), CX
+	CPUID
+	MOVL AX, eax+8(FP)
+	MOVL BX, ebx+12(FP)
+	MOVL CX, ecx+16(FP)
+	MOVL DX, edx+20(FP)
+	RET
+
+// func rdpid() uint32
+TEXT ·rdpid(SB), NOSPLIT, $0-4
+	// RDPID AX
+	BYTE $0xF3; BYTE $0x0F; BYTE $0xC7; BYTE $0xF8
+	MOVL AX, ret+0(FP)
+	RET
+
+// func rdtscp() uint32
+TEXT ·rdtscp(SB), NOSPLIT, $0-4
+	RDTSCP
+	MOVL CX, ret+0(FP)
+	RET
@@ -0,0 +1,41 @@
+//go:build !purego
+
+package percpu
+
+import (
+	"runtime"
+	"testing"
+)
+
+func TestFastCPUID(t *testing.T) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	cpu, ok := fastCPUID()
+	if !ok {
+		t.Skip("RDPID and RDTSCP are not available")
+	}
+	// The thread can migrate between the reads, so retry a few times.
+	for i := 0; i < 10; i++ {
+		if want := getcpu(); cpu == want {
+			return
+		}
+		cpu, _ = fastCPUID()
+	}
+	t.Fatalf("got CPU %d; want %d", cpu, getcpu())
+}
+
+func BenchmarkCPUProcID(b *testing.B) {
+	b.Run("Fast", func(b *testing.B) {
+		if _, ok := fastCPUID(); !ok {
+			b.Skip("RDPID and RDTSCP are not available")
+		}
+		for i := 0; i < b.N; i++ {
+			fastCPUID()
+		}
+	})
+	b.Run("Syscall", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			getcpu()
+		}
+	})
+}
@@ -0,0 +1,8 @@
+//go:build linux && (!amd64 || purego)
+
+package percpu
+
+// fastCPUID is not available, so cpuProcID uses the system call.
+func fastCPUID() (int, bool) {
+	return 0, false
+}
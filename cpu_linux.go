@@ -0,0 +1,26 @@
+package percpu
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+// cpuProcID returns the number of the CPU the calling thread runs on, or the
+// processor ID if the getcpu system call fails.
+func cpuProcID() int {
+	if cpu, ok := fastCPUID(); ok {
+		return cpu
+	}
+	return getcpu()
+}
+
+// getcpu returns the number of the CPU the calling thread runs on using the
+// getcpu system call, or the processor ID if the system call fails.
+func getcpu() int {
+	var cpu uint32
+	_, _, errno := syscall.RawSyscall(sysGetcpu, uintptr(unsafe.Pointer(&cpu)), 0, 0)
+	if errno != 0 {
+		return procPinID()
+	}
+	return int(cpu)
+}
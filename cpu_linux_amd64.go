@@ -0,0 +1,5 @@
+package percpu
+
+// sysGetcpu is the number of the getcpu system call, which package syscall
+// does not define on linux/amd64.
+const sysGetcpu = 309
@@ -0,0 +1,10 @@
+//go:build linux && !amd64
+
+package percpu
+
+import (
+	"syscall"
+)
+
+// sysGetcpu is the number of the getcpu system call.
+const sysGetcpu = syscall.SYS_GETCPU
@@ -0,0 +1,9 @@
+//go:build !linux
+
+package percpu
+
+// cpuProcID falls back to the processor ID, as there is no portable way to get
+// the number of the CPU the calling thread runs on.
+func cpuProcID() int {
+	return procPinID()
+}
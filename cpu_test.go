@@ -0,0 +1,20 @@
+package percpu
+
+import (
+	"testing"
+)
+
+func TestCPUProcID(t *testing.T) {
+	// CPU numbers can exceed NumCPU if the process is bound to a subset of
+	// the CPUs, but not by this much.
+	for i := 0; i < 100; i++ {
+		if id := cpuProcID(); id < 0 || id > 1<<20 {
+			t.Fatalf("got CPU %d", id)
+		}
+	}
+	c := NewCounter(WithPicker(PickCPU))
+	c.Add(1)
+	if n := c.Load(); n != 1 {
+		t.Fatalf("got %d; want 1", n)
+	}
+}
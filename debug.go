@@ -0,0 +1,33 @@
+//go:build percpudebug
+
+package percpu
+
+import (
+	"sync/atomic"
+)
+
+// debugEnabled reports whether the package is built with the percpudebug
+// build tag.
+const debugEnabled = true
+
+// shardHeat counts accesses of a shard and migrations away from it.
+type shardHeat struct {
+	hits       atomic.Uint64
+	migrations atomic.Uint64
+}
+
+func (h *shardHeat) hit() {
+	h.hits.Add(1)
+}
+
+func (h *shardHeat) migrate() {
+	h.migrations.Add(1)
+}
+
+func (h *shardHeat) load() uint64 {
+	return h.hits.Load()
+}
+
+func (h *shardHeat) loadMigrations() uint64 {
+	return h.migrations.Load()
+}
@@ -0,0 +1,57 @@
+package percpu
+
+import (
+	"strconv"
+	"time"
+)
+
+// A DurationCounter accumulates time.Duration values, like the time spent
+// serving requests, and may be efficiently updated by many goroutines
+// concurrently. It is a Counter of nanoseconds.
+//
+// Like Counter, Load and Reset do not observe a consistent view of the total
+// if they are called concurrently to Add. See Counter for the guarantees.
+type DurationCounter struct {
+	c Counter
+}
+
+// NewDurationCounter returns a fresh DurationCounter initialized to zero.
+// The options configure the sharding of the DurationCounter and its Clock.
+func NewDurationCounter(opts ...Option) *DurationCounter {
+	d := &DurationCounter{}
+	d.c.vs.opts.apply(opts)
+	return d
+}
+
+// Add adds dur to the total duration.
+func (d *DurationCounter) Add(dur time.Duration) {
+	d.c.Add(int64(dur))
+}
+
+// AddSince adds the time elapsed since t, as measured by the Clock of the
+// DurationCounter (see WithClock).
+func (d *DurationCounter) AddSince(t time.Time) {
+	d.c.AddSince(t)
+}
+
+// Time runs fn and adds its duration.
+func (d *DurationCounter) Time(fn func()) {
+	d.c.Time(fn)
+}
+
+// Load computes the total duration.
+func (d *DurationCounter) Load() time.Duration {
+	return time.Duration(d.c.Load())
+}
+
+// Reset sets the total duration to zero and reports the old value.
+func (d *DurationCounter) Reset() time.Duration {
+	return time.Duration(d.c.Reset())
+}
+
+// String returns the total in nanoseconds, in decimal, so that
+// DurationCounter implements expvar.Var. Use Load().String() for
+// a human-readable duration.
+func (d *DurationCounter) String() string {
+	return strconv.FormatInt(int64(d.Load()), 10)
+}
@@ -0,0 +1,23 @@
+package percpu
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDurationCounter(t *testing.T) {
+	clock := &stepClock{}
+	d := NewDurationCounter(WithClock(clock))
+	d.Add(500 * time.Millisecond)
+	d.AddSince(clock.now)
+	d.Time(func() {})
+	if got, want := d.Load(), 2500*time.Millisecond; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+	if got, want := d.Reset(), 2500*time.Millisecond; got != want {
+		t.Fatalf("Reset returned %v; want %v", got, want)
+	}
+	if got := d.Load(); got != 0 {
+		t.Fatalf("got %v after Reset", got)
+	}
+}
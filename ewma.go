@@ -0,0 +1,82 @@
+package percpu
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// An EWMA is an exponentially weighted moving average of the rate of events,
+// like requests per second. It may be efficiently updated by many goroutines
+// concurrently.
+//
+// Add only adds to a Counter, so it does not contend with other goroutines.
+// Rate folds the events counted since the previous call into the average,
+// decayed by the time elapsed since then, as measured by the Clock of the
+// EWMA (see WithClock). The events of that interval are assumed to be spread
+// evenly over it, so the result depends little on how often Rate is called,
+// and no background goroutine is needed.
+type EWMA struct {
+	c Counter
+
+	mu   sync.Mutex
+	avg  ewmaRate
+	last time.Time
+}
+
+// ewmaRate is an exponentially weighted moving average of a rate in events
+// per second, with a time constant of tau seconds.
+type ewmaRate struct {
+	tau  float64
+	rate float64
+}
+
+// update folds n events that happened during the last dt seconds into the
+// average.
+func (r *ewmaRate) update(n int64, dt float64) {
+	// The weight of the new interval. Expm1 keeps it precise for intervals
+	// much shorter than tau.
+	w := -math.Expm1(-dt / r.tau)
+	r.rate += w * (float64(n)/dt - r.rate)
+}
+
+// NewEWMA returns an EWMA with a rate of zero, averaging over the given time
+// window: the weight of events decays by a factor of e every window.
+// The options configure the sharding of the counter and the Clock.
+// It panics if window is not positive.
+func NewEWMA(window time.Duration, opts ...Option) *EWMA {
+	if window <= 0 {
+		panic("percpu: NewEWMA with non-positive window")
+	}
+	e := &EWMA{avg: ewmaRate{tau: window.Seconds()}}
+	e.c.vs.opts.apply(opts)
+	e.last = e.c.vs.opts.now()
+	return e
+}
+
+// Add records n events.
+func (e *EWMA) Add(n int64) {
+	e.c.Add(n)
+}
+
+// Rate returns the average rate of events per second.
+func (e *EWMA) Rate() float64 {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	now := e.c.vs.opts.now()
+	if dt := now.Sub(e.last).Seconds(); dt > 0 {
+		e.avg.update(e.c.Reset(), dt)
+		e.last = now
+	}
+	return e.avg.rate
+}
+
+// Reset sets the rate to zero and forgets the events recorded since the last
+// call to Rate.
+func (e *EWMA) Reset() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.c.Reset()
+	e.avg.rate = 0
+	e.last = e.c.vs.opts.now()
+}
@@ -0,0 +1,66 @@
+package percpu
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+// manualClock is a Clock that only moves when told to.
+type manualClock struct {
+	now time.Time
+}
+
+func (c *manualClock) Now() time.Time {
+	return c.now
+}
+
+func TestEWMA(t *testing.T) {
+	clock := &manualClock{now: time.Unix(0, 0)}
+	e := NewEWMA(time.Minute, WithClock(clock))
+	if got := e.Rate(); got != 0 {
+		t.Fatalf("got rate %v initially; want 0", got)
+	}
+	// A steady 10 events per second converges to a rate of 10, no matter
+	// how often the rate is read.
+	for i := 0; i < 6000; i++ {
+		e.Add(1)
+		clock.now = clock.now.Add(100 * time.Millisecond)
+		if i%7 == 0 {
+			e.Rate()
+		}
+	}
+	if got := e.Rate(); math.Abs(got-10) > 0.01 {
+		t.Fatalf("got rate %v; want 10", got)
+	}
+	// Without events, the rate decays by a factor of e each window.
+	clock.now = clock.now.Add(time.Minute)
+	if got, want := e.Rate(), 10/math.E; math.Abs(got-want) > 0.01 {
+		t.Fatalf("got rate %v after an idle minute; want %v", got, want)
+	}
+	// Reading the rate twice in one instant does not change it.
+	if a, b := e.Rate(), e.Rate(); a != b {
+		t.Fatalf("got rates %v, %v without time passing", a, b)
+	}
+	e.Add(5)
+	e.Reset()
+	clock.now = clock.now.Add(time.Second)
+	if got := e.Rate(); got != 0 {
+		t.Fatalf("got rate %v after Reset; want 0", got)
+	}
+}
+
+func TestEWMARateIndependentOfReads(t *testing.T) {
+	clock := &manualClock{now: time.Unix(0, 0)}
+	often := NewEWMA(10*time.Second, WithClock(clock))
+	rarely := NewEWMA(10*time.Second, WithClock(clock))
+	for i := 0; i < 30; i++ {
+		often.Add(20)
+		rarely.Add(20)
+		clock.now = clock.now.Add(time.Second)
+		often.Rate()
+	}
+	if a, b := often.Rate(), rarely.Rate(); math.Abs(a-b) > 0.5 {
+		t.Fatalf("got rate %v when read every second and %v when read once", a, b)
+	}
+}
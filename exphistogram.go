@@ -0,0 +1,381 @@
+package percpu
+
+import (
+	"math"
+	"sync"
+)
+
+// Scales of an ExponentialHistogram, as defined by OpenTelemetry.
+const (
+	MinExponentialScale = -10
+	MaxExponentialScale = 20
+)
+
+// MaxExponentialBuckets is the number of buckets each shard of an
+// ExponentialHistogram keeps at most for the positive and for the negative
+// values, like the default maximum size of the OpenTelemetry SDK.
+const MaxExponentialBuckets = 160
+
+// An ExponentialHistogram counts observed float64 values in buckets whose
+// bounds grow exponentially, like the base-2 exponential histogram of
+// OpenTelemetry. It may be efficiently updated by many goroutines
+// concurrently.
+//
+// The scale determines the resolution: the bounds of consecutive buckets
+// differ by a factor of 2^(2^-scale), so at scale 0 each bucket spans a power
+// of two, and at scale 3 the relative error of a value reconstructed from its
+// bucket is below 5%. Positive and negative values are counted in separate
+// buckets by their absolute value, and zeros have a bucket of their own.
+//
+// The scale set when creating the histogram is the highest one it uses. Each
+// shard allocates buckets for the range of values observed on it, up to
+// MaxExponentialBuckets for each sign. Like in the OpenTelemetry SDK, a shard
+// that observes a value outside of that range lowers its scale until the
+// range fits, which merges pairs of neighboring buckets for each step. Load
+// merges the shards at the lowest scale among them. The resolution of the
+// histogram thus adapts to the ratio of the largest and smallest values
+// observed since the last Reset, which resets the scale, and the memory of
+// each shard is bounded.
+//
+// Like Counter, Load and Reset do not observe a consistent view of the shards
+// if they are called concurrently to Observe.
+type ExponentialHistogram struct {
+	scale int
+	vs    Values[expHistogramShard]
+}
+
+type expHistogramShard struct {
+	mu sync.Mutex
+	// s holds the observations of the shard. Its scale is set to the scale of
+	// the histogram by the first observation.
+	s ExponentialHistogramSnapshot
+}
+
+// ExponentialBuckets holds the counts of consecutive buckets of an
+// ExponentialHistogram, like the buckets of an OpenTelemetry
+// ExponentialHistogramDataPoint.
+type ExponentialBuckets struct {
+	// Offset is the index of the bucket of the first count.
+	// Bucket i counts the values x with LowerBound(i) < |x| <= LowerBound(i+1).
+	Offset int
+	// Counts holds the number of values in each bucket, or their total
+	// weight if ObserveWeighted was used, starting at Offset.
+	Counts []float64
+}
+
+// increment adds 1 to the count of bucket i, extending Counts if needed.
+func (b *ExponentialBuckets) increment(i int) {
+	b.add(i, 1)
+}
+
+// add adds n to the count of bucket i, extending Counts if needed.
+func (b *ExponentialBuckets) add(i int, n float64) {
+	switch {
+	case len(b.Counts) == 0:
+		b.Offset = i
+		b.Counts = append(b.Counts, n)
+		return
+	case i < b.Offset:
+		grown := make([]float64, b.Offset-i+len(b.Counts))
+		copy(grown[b.Offset-i:], b.Counts)
+		b.Counts = grown
+		b.Offset = i
+	case i >= b.Offset+len(b.Counts):
+		b.Counts = append(b.Counts, make([]float64, i-b.Offset-len(b.Counts)+1)...)
+	}
+	b.Counts[i-b.Offset] += n
+}
+
+// change returns how many times the scale of b needs to be decremented, so
+// that the buckets from lo to hi fit into MaxExponentialBuckets together with
+// the buckets of b.
+func (b *ExponentialBuckets) change(lo, hi int) int {
+	if len(b.Counts) > 0 {
+		if b.Offset < lo {
+			lo = b.Offset
+		}
+		if end := b.Offset + len(b.Counts) - 1; end > hi {
+			hi = end
+		}
+	}
+	c := 0
+	for hi>>c-lo>>c >= MaxExponentialBuckets {
+		c++
+	}
+	return c
+}
+
+// downscale decrements the scale of b by change, which merges bucket i into
+// bucket i>>change. It does not modify the old Counts, which may be shared.
+func (b *ExponentialBuckets) downscale(change int) {
+	if change == 0 || len(b.Counts) == 0 {
+		return
+	}
+	offset := b.Offset >> change
+	counts := make([]float64, (b.Offset+len(b.Counts)-1)>>change-offset+1)
+	for j, n := range b.Counts {
+		counts[(b.Offset+j)>>change-offset] += n
+	}
+	b.Offset, b.Counts = offset, counts
+}
+
+// merge adds the counts of o, which must have the same scale, to b.
+func (b *ExponentialBuckets) merge(o ExponentialBuckets) {
+	for j, n := range o.Counts {
+		if n != 0 {
+			b.add(o.Offset+j, n)
+		}
+	}
+}
+
+// ExponentialHistogramSnapshot holds the merged counts of an
+// ExponentialHistogram. It maps directly to an OpenTelemetry
+// ExponentialHistogramDataPoint with a zero threshold of 0. The counts are
+// float64 to keep the weights of ObserveWeighted exactly. With integer
+// weights, they convert exactly to the uint64 counts of OpenTelemetry, up to
+// 2^53.
+type ExponentialHistogramSnapshot struct {
+	// Scale is the scale of the histogram.
+	Scale int
+	// Count is the total number of observations, or their total weight if
+	// ObserveWeighted was used.
+	Count float64
+	// Sum is the sum of the observed values.
+	Sum float64
+	// Min and Max are the smallest and largest observed values.
+	// They are zero if there were no observations.
+	Min, Max float64
+	// ZeroCount is the number of observed zeros, or their total weight.
+	ZeroCount float64
+	// Positive and Negative hold the counts of the positive and negative
+	// values, by their absolute value.
+	Positive, Negative ExponentialBuckets
+}
+
+// LowerBound returns the exclusive lower bound of the absolute values counted
+// in the bucket with the given index, which is the inclusive upper bound of
+// the bucket below.
+func (s ExponentialHistogramSnapshot) LowerBound(index int) float64 {
+	return s.bound(float64(index))
+}
+
+// bound returns LowerBound for a fractional index, which interpolates
+// exponentially between the bounds of a bucket.
+func (s ExponentialHistogramSnapshot) bound(index float64) float64 {
+	return math.Exp2(math.Ldexp(index, -s.Scale))
+}
+
+// Quantile returns an estimate of the value below which the fraction q of the
+// observed values lie, for example the 99th percentile for q = 0.99. q is
+// clamped to the range from 0 to 1, which return the exact minimum and
+// maximum.
+//
+// The estimate interpolates exponentially between the bounds of the bucket
+// that holds the rank q*Count, as if the logarithms of the values were spread
+// uniformly within the bucket, so its relative error is less than the ratio of
+// the bounds of a bucket. Zeros are exact, and the estimate is clamped to Min
+// and Max. It returns 0 if there were no observations.
+func (s ExponentialHistogramSnapshot) Quantile(q float64) float64 {
+	if s.Count == 0 {
+		return 0
+	}
+	q = math.Max(0, math.Min(1, q))
+	v := s.quantile(q * s.Count)
+	return math.Max(s.Min, math.Min(s.Max, v))
+}
+
+// quantile returns the estimate of the value with the given rank, counting
+// from the smallest negative value.
+func (s ExponentialHistogramSnapshot) quantile(rank float64) float64 {
+	// The negative values are ordered from the largest absolute value, so
+	// their buckets are walked backwards, and a bucket from its upper bound.
+	neg := s.Negative
+	for j := len(neg.Counts) - 1; j >= 0; j-- {
+		c := neg.Counts[j]
+		if c > 0 && rank <= c {
+			return -s.bound(float64(neg.Offset+j+1) - rank/c)
+		}
+		rank -= c
+	}
+	zeros := s.ZeroCount
+	if zeros > 0 && rank <= zeros {
+		return 0
+	}
+	rank -= zeros
+	pos := s.Positive
+	for j, c := range pos.Counts {
+		if c > 0 && rank <= c {
+			return s.bound(float64(pos.Offset+j) + rank/c)
+		}
+		rank -= c
+	}
+	// Rounding left the rank above the total.
+	return s.Max
+}
+
+// Quantiles returns the Quantile of each of qs.
+func (s ExponentialHistogramSnapshot) Quantiles(qs ...float64) []float64 {
+	values := make([]float64, len(qs))
+	for i, q := range qs {
+		values[i] = s.Quantile(q)
+	}
+	return values
+}
+
+// downscale decrements the scale of s by change.
+func (s *ExponentialHistogramSnapshot) downscale(change int) {
+	s.Scale -= change
+	s.Positive.downscale(change)
+	s.Negative.downscale(change)
+}
+
+// merge adds the observations of o to s, lowering the scale of s to the scale
+// of o, and further if needed to fit the buckets into MaxExponentialBuckets.
+func (s *ExponentialHistogramSnapshot) merge(o ExponentialHistogramSnapshot) {
+	if o.Count == 0 {
+		return
+	}
+	if s.Scale > o.Scale {
+		s.downscale(s.Scale - o.Scale)
+	}
+	o.downscale(o.Scale - s.Scale)
+	var change int
+	for _, b := range [...]struct{ s, o *ExponentialBuckets }{
+		{&s.Positive, &o.Positive},
+		{&s.Negative, &o.Negative},
+	} {
+		if n := len(b.o.Counts); n > 0 {
+			if c := b.s.change(b.o.Offset, b.o.Offset+n-1); c > change {
+				change = c
+			}
+		}
+	}
+	s.downscale(change)
+	o.downscale(change)
+	if s.Count == 0 || o.Min < s.Min {
+		s.Min = o.Min
+	}
+	if s.Count == 0 || o.Max > s.Max {
+		s.Max = o.Max
+	}
+	s.Count += o.Count
+	s.Sum += o.Sum
+	s.ZeroCount += o.ZeroCount
+	s.Positive.merge(o.Positive)
+	s.Negative.merge(o.Negative)
+}
+
+// NewExponentialHistogram returns an ExponentialHistogram without
+// observations, using the given scale as the highest one.
+// The options configure the sharding of the histogram.
+// It panics if scale is outside of the range from MinExponentialScale to
+// MaxExponentialScale.
+func NewExponentialHistogram(scale int, opts ...Option) *ExponentialHistogram {
+	if scale < MinExponentialScale || scale > MaxExponentialScale {
+		panic("percpu: NewExponentialHistogram with invalid scale")
+	}
+	h := &ExponentialHistogram{scale: scale}
+	h.vs.opts.apply(opts)
+	return h
+}
+
+// bucketIndex returns the index of the bucket of v > 0 at the highest scale
+// of h, following the OpenTelemetry specification: bucket i holds the values
+// in (base^i, base^(i+1)], where base is 2^(2^-scale).
+func (h *ExponentialHistogram) bucketIndex(v float64) int {
+	frac, exp := math.Frexp(v)
+	// v = frac * 2^exp with frac in [0.5, 1), so v is in [2^k, 2^(k+1)).
+	k := exp - 1
+	if frac == 0.5 {
+		// Exact powers of two are the inclusive upper bound of the bucket
+		// below, which the logarithm could miss because of rounding.
+		if h.scale > 0 {
+			return k<<h.scale - 1
+		}
+		return (k - 1) >> -h.scale
+	}
+	if h.scale <= 0 {
+		return k >> -h.scale
+	}
+	return int(math.Ceil(math.Log(v)*math.Ldexp(math.Log2E, h.scale))) - 1
+}
+
+// Observe records x. Infinities and NaN are ignored, because they cannot be
+// represented in the buckets.
+func (h *ExponentialHistogram) Observe(x float64) {
+	h.ObserveWeighted(x, 1)
+}
+
+// ObserveWeighted records x with the given weight, as if x was observed
+// weight times, for example to record pre-aggregated values, or sampled values
+// with the inverse of their sampling probability. The weight does not need to
+// be an integer. Weights that are not positive and finite are ignored, like
+// infinite and NaN values.
+func (h *ExponentialHistogram) ObserveWeighted(x, weight float64) {
+	if math.IsInf(x, 0) || math.IsNaN(x) || !(weight > 0) || math.IsInf(weight, 0) {
+		return
+	}
+	var i int
+	if x != 0 {
+		i = h.bucketIndex(math.Abs(x))
+	}
+	sh := h.vs.Get()
+	sh.mu.Lock()
+	s := &sh.s
+	if s.Count == 0 {
+		s.Scale = h.scale
+	}
+	if x != 0 {
+		// i is the index at the scale of h, which is lowered by the scale of
+		// the shard.
+		i >>= h.scale - s.Scale
+		b := &s.Positive
+		if x < 0 {
+			b = &s.Negative
+		}
+		if c := b.change(i, i); c > 0 {
+			s.downscale(c)
+			i >>= c
+		}
+	}
+	if s.Count == 0 || x < s.Min {
+		s.Min = x
+	}
+	if s.Count == 0 || x > s.Max {
+		s.Max = x
+	}
+	s.Count += weight
+	s.Sum += x * weight
+	switch {
+	case x > 0:
+		s.Positive.add(i, weight)
+	case x < 0:
+		s.Negative.add(i, weight)
+	default:
+		s.ZeroCount += weight
+	}
+	sh.mu.Unlock()
+}
+
+// Load merges the counts of all shards.
+func (h *ExponentialHistogram) Load() ExponentialHistogramSnapshot {
+	return h.merge(false)
+}
+
+// Reset forgets all observations and reports what Load would return.
+func (h *ExponentialHistogram) Reset() ExponentialHistogramSnapshot {
+	return h.merge(true)
+}
+
+func (h *ExponentialHistogram) merge(reset bool) ExponentialHistogramSnapshot {
+	total := ExponentialHistogramSnapshot{Scale: h.scale}
+	h.vs.Range(func(sh *expHistogramShard) {
+		sh.mu.Lock()
+		total.merge(sh.s)
+		if reset {
+			sh.s = ExponentialHistogramSnapshot{}
+		}
+		sh.mu.Unlock()
+	})
+	return total
+}
@@ -0,0 +1,232 @@
+package percpu
+
+import (
+	"math"
+	"math/rand"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestExponentialBucketIndex(t *testing.T) {
+	for _, tt := range []struct {
+		scale int
+		v     float64
+		want  int
+	}{
+		{0, 1, -1},
+		{0, 1.5, 0},
+		{0, 2, 0},
+		{0, 3, 1},
+		{0, 0.75, -1},
+		{1, 2, 1},
+		{1, 1.5, 1},
+		{1, 1.2, 0},
+		{-1, 1, -1},
+		{-1, 2, 0},
+		{-1, 4, 0},
+		{-1, 5, 1},
+		{-1, 8, 1},
+		{3, 1024, 79},
+	} {
+		h := NewExponentialHistogram(tt.scale)
+		if got := h.bucketIndex(tt.v); got != tt.want {
+			t.Errorf("scale %d: bucketIndex(%v) = %d; want %d", tt.scale, tt.v, got, tt.want)
+		}
+	}
+}
+
+func TestExponentialBucketBounds(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for scale := MinExponentialScale; scale <= MaxExponentialScale; scale++ {
+		h := NewExponentialHistogram(scale)
+		s := ExponentialHistogramSnapshot{Scale: scale}
+		for j := 0; j < 100; j++ {
+			v := math.Exp(r.NormFloat64() * 20)
+			i := h.bucketIndex(v)
+			// Allow for rounding of the bounds.
+			lo, hi := s.LowerBound(i)*(1-1e-12), s.LowerBound(i+1)*(1+1e-12)
+			if !(lo < v && v <= hi) {
+				t.Fatalf("scale %d: %v in bucket %d with bounds (%v, %v]", scale, v, i, lo, hi)
+			}
+		}
+	}
+}
+
+func TestExponentialHistogram(t *testing.T) {
+	setProcIDs(t, 2, 0, 1, 0, 1, 0, 1, 0)
+	h := NewExponentialHistogram(0)
+	for _, x := range []float64{3, 1.5, 0, -2, 8, math.NaN(), 1.25} {
+		h.Observe(x)
+	}
+	got := h.Load()
+	want := ExponentialHistogramSnapshot{
+		Scale:     0,
+		Count:     6,
+		Sum:       11.75,
+		Min:       -2,
+		Max:       8,
+		ZeroCount: 1,
+		Positive:  ExponentialBuckets{Offset: 0, Counts: []float64{2, 1, 1}},
+		Negative:  ExponentialBuckets{Offset: 0, Counts: []float64{1}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v; want %+v", got, want)
+	}
+	if r := h.Reset(); !reflect.DeepEqual(r, want) {
+		t.Fatalf("Reset returned %+v; want %+v", r, want)
+	}
+	if got := h.Load(); got.Count != 0 || len(got.Positive.Counts) != 0 {
+		t.Fatalf("got %+v after Reset", got)
+	}
+}
+
+func TestExponentialHistogramObserveWeighted(t *testing.T) {
+	h := NewExponentialHistogram(0, WithShards(1))
+	h.ObserveWeighted(3, 2.5)
+	h.ObserveWeighted(-1, 2)
+	h.ObserveWeighted(0, 1)
+	h.ObserveWeighted(100, 0)
+	h.ObserveWeighted(100, math.NaN())
+	got := h.Load()
+	want := ExponentialHistogramSnapshot{
+		Scale:     0,
+		Count:     5.5,
+		Sum:       5.5,
+		Min:       -1,
+		Max:       3,
+		ZeroCount: 1,
+		Positive:  ExponentialBuckets{Offset: 1, Counts: []float64{2.5}},
+		Negative:  ExponentialBuckets{Offset: -1, Counts: []float64{2}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v; want %+v", got, want)
+	}
+}
+
+func TestExponentialHistogramQuantile(t *testing.T) {
+	h := NewExponentialHistogram(0, WithShards(1))
+	if got := h.Load().Quantile(0.5); got != 0 {
+		t.Fatalf("got %v without observations; want 0", got)
+	}
+	for _, x := range []float64{3, 1.5, 0, -2, 8, 1.25, -6} {
+		h.Observe(x)
+	}
+	s := h.Load()
+	// In rank order, the values are -6, -2, 0, 1.25, 1.5, 3, 8, in the buckets
+	// [-8, -4), [-2, -1), zero, (1, 2] twice, (2, 4] and (4, 8].
+	for _, tt := range []struct {
+		q, want float64
+	}{
+		{0, -6},
+		{0.5 / 7, -math.Sqrt(32)},
+		{1.5 / 7, -math.Sqrt(2)},
+		{2.5 / 7, 0},
+		{4 / 7.0, math.Sqrt(2)},
+		{5.5 / 7, math.Sqrt(8)},
+		{1, 8},
+	} {
+		if got := s.Quantile(tt.q); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("Quantile(%v) = %v; want %v", tt.q, got, tt.want)
+		}
+	}
+
+	h = NewExponentialHistogram(3, WithShards(1))
+	for i := 1; i <= 1000; i++ {
+		h.Observe(float64(i))
+	}
+	s = h.Load()
+	qs := s.Quantiles(0, 0.5, 0.9, 0.99, 1)
+	for i, want := range []float64{1, 500, 900, 990, 1000} {
+		if math.Abs(qs[i]-want)/want > 0.1 {
+			t.Errorf("got quantile %v; want about %v", qs[i], want)
+		}
+	}
+}
+
+func TestExponentialBucketsGrow(t *testing.T) {
+	var b ExponentialBuckets
+	b.increment(3)
+	b.increment(1)
+	b.increment(5)
+	b.increment(3)
+	if want := (ExponentialBuckets{Offset: 1, Counts: []float64{1, 0, 2, 0, 1}}); !reflect.DeepEqual(b, want) {
+		t.Fatalf("got %+v; want %+v", b, want)
+	}
+}
+
+func TestExponentialHistogramDownscale(t *testing.T) {
+	setProcIDs(t, 2, 0, 0, 1, 0, 0, 0, 0, 0, 0)
+	h := NewExponentialHistogram(MaxExponentialScale)
+	h.Observe(1)
+	h.Observe(1e6)
+	if s := h.vs.Shard(0).s; len(s.Positive.Counts) > MaxExponentialBuckets || s.Scale >= MaxExponentialScale {
+		t.Fatalf("shard has %d buckets at scale %d", len(s.Positive.Counts), s.Scale)
+	}
+	h.Observe(1.5)
+	h.Observe(-3)
+	got := h.Load()
+
+	// A single shard observing the same values ends up with the same scale.
+	want := NewExponentialHistogram(MaxExponentialScale, WithShards(1))
+	for _, x := range []float64{1, 1e6, 1.5, -3} {
+		want.Observe(x)
+	}
+	if w := want.Load(); !reflect.DeepEqual(got, w) {
+		t.Fatalf("got %+v; want %+v", got, w)
+	}
+	if n := len(got.Positive.Counts); n > MaxExponentialBuckets {
+		t.Fatalf("got %d buckets", n)
+	}
+	for _, x := range []float64{1.5, 1e6} {
+		i := h.bucketIndex(x) >> (MaxExponentialScale - got.Scale)
+		if !(got.LowerBound(i) < x && x <= got.LowerBound(i+1)) || got.Positive.Counts[i-got.Positive.Offset] != 1 {
+			t.Errorf("%v is not counted in bucket %d: %+v", x, i, got.Positive)
+		}
+	}
+
+	// Reset restores the highest scale.
+	h.Reset()
+	h.Observe(1.5)
+	if s := h.Load(); s.Scale != MaxExponentialScale {
+		t.Fatalf("got scale %d after Reset; want %d", s.Scale, MaxExponentialScale)
+	}
+}
+
+func TestExponentialBucketsDownscale(t *testing.T) {
+	b := ExponentialBuckets{Offset: -3, Counts: []float64{1, 2, 3, 4, 5}}
+	counts := b.Counts
+	b.downscale(1)
+	if want := (ExponentialBuckets{Offset: -2, Counts: []float64{1, 5, 9}}); !reflect.DeepEqual(b, want) {
+		t.Fatalf("got %+v; want %+v", b, want)
+	}
+	if counts[0] != 1 || counts[4] != 5 {
+		t.Fatalf("downscale modified the old counts %v", counts)
+	}
+	if c := b.change(0, MaxExponentialBuckets*3); c != 2 {
+		t.Fatalf("got change %d; want 2", c)
+	}
+}
+
+func TestExponentialHistogramConcurrent(t *testing.T) {
+	h := NewExponentialHistogram(2)
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 1; j <= 100; j++ {
+				h.Observe(float64(j))
+			}
+		}()
+	}
+	wg.Wait()
+	got := h.Load()
+	var n float64
+	for _, c := range got.Positive.Counts {
+		n += c
+	}
+	if got.Count != 1000 || n != 1000 || got.Sum != 50500 || got.Min != 1 || got.Max != 100 {
+		t.Fatalf("got %+v", got)
+	}
+}
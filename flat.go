@@ -0,0 +1,65 @@
+package percpu
+
+import (
+	"github.com/martin-sucha/percpu/internal/hooks"
+)
+
+// Flat is a sharded set of values like Values, with a different memory
+// layout: all shards are stored inline in one contiguous, padded array, which
+// is allocated when the Flat is created. Get is a single indexed load instead
+// of following a pointer to an individually allocated shard, which makes Flat
+// a better fit for small values like counters.
+//
+// The number of shards is fixed when the Flat is created, see NewFlat.
+// Processor IDs are mapped onto the shards modulo the number of shards, so
+// if GOMAXPROCS increases later, several processors share a shard.
+//
+// Flat must be created with NewFlat and must not be copied.
+type Flat[T any] struct {
+	_      noCopy
+	shards []padded[T]
+	opts   options
+}
+
+// NewFlat returns a new Flat configured by opts.
+// It has one shard for each processor, unless the options set the number of
+// shards.
+func NewFlat[T any](opts ...Option) *Flat[T] {
+	f := &Flat[T]{}
+	f.opts.apply(opts)
+	f.shards = make([]padded[T], f.opts.shardCount())
+	return f
+}
+
+// Get returns a pointer to one of the values in f, like Values.Get.
+// All access of the returned value must use further synchronization
+// mechanisms.
+func (f *Flat[T]) Get() *T {
+	id, hooked := f.opts.shardID()
+	if id >= len(f.shards) {
+		id %= len(f.shards)
+	}
+	slot := &f.shards[id]
+	slot.heat.hit()
+	if hooked {
+		if h := hooks.Load(); h != nil && h.AfterGet != nil {
+			h.AfterGet()
+		}
+	}
+	return &slot.v
+}
+
+// Range runs fn on all values in f, in the order of their shard index.
+//
+// The pointers might be concurrently used by other goroutines.
+// The user is responsible for synchronizing access to p.
+func (f *Flat[T]) Range(fn func(p *T)) {
+	for i := range f.shards {
+		fn(&f.shards[i].v)
+	}
+}
+
+// NumShards returns the number of shards of f.
+func (f *Flat[T]) NumShards() int {
+	return len(f.shards)
+}
@@ -0,0 +1,56 @@
+package percpu
+
+import (
+	"sync/atomic"
+	"testing"
+)
+
+func TestFlat(t *testing.T) {
+	setProcIDs(t, 3, 0, 1, 2, 5, 1)
+	f := NewFlat[int]()
+	if n := f.NumShards(); n != 3 {
+		t.Fatalf("got %d shards; want 3", n)
+	}
+	for i := 0; i < 5; i++ {
+		*f.Get() += 1
+	}
+	var got []int
+	f.Range(func(p *int) { got = append(got, *p) })
+	// Proc 5 is mapped onto shard 2.
+	if len(got) != 3 || got[0] != 1 || got[1] != 2 || got[2] != 2 {
+		t.Fatalf("got shards %v; want [1 2 2]", got)
+	}
+}
+
+func TestFlatWithShards(t *testing.T) {
+	setProcIDs(t, 0, 0, 1, 2, 3)
+	f := NewFlat[atomic.Int64](WithShards(2))
+	for i := 0; i < 4; i++ {
+		f.Get().Add(25)
+	}
+	var sum int64
+	f.Range(func(p *atomic.Int64) { sum += p.Load() })
+	if f.NumShards() != 2 || sum != 100 {
+		t.Fatalf("got %d shards with sum %d; want 2 shards with sum 100", f.NumShards(), sum)
+	}
+}
+
+// Compare the layouts of Values and Flat.
+func BenchmarkLayout(b *testing.B) {
+	b.Run("Values", func(b *testing.B) {
+		var vs Values[atomic.Int64]
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				vs.Get().Add(1)
+			}
+		})
+	})
+	b.Run("Flat", func(b *testing.B) {
+		f := NewFlat[atomic.Int64]()
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				f.Get().Add(1)
+			}
+		})
+	})
+}
@@ -0,0 +1,98 @@
+package percpu
+
+import (
+	"math"
+	"strconv"
+	"sync"
+)
+
+// A FloatAccumulator is a float64 sum which may be efficiently updated by many
+// goroutines concurrently, like FloatCounter, but which keeps the rounding
+// error of the sum small.
+//
+// Each shard keeps a compensation term next to its sum, which collects the
+// low-order bits lost when adding a value to the sum (Kahan-Babuška-Neumaier
+// summation). Load merges the shards and their compensation terms the same
+// way. The result is as accurate as if the values were summed with twice the
+// precision of float64, so many tiny additions to a large total are not lost.
+// This makes Add slower than that of FloatCounter.
+//
+// Like Counter, Load and Reset do not observe a consistent view of the total
+// if they are called concurrently to Add. See Counter for the guarantees.
+type FloatAccumulator struct {
+	vs Values[floatAccumulatorShard]
+}
+
+type floatAccumulatorShard struct {
+	mu  sync.Mutex
+	sum neumaierSum
+}
+
+// neumaierSum is a float64 sum with a compensation term.
+type neumaierSum struct {
+	sum, c float64
+}
+
+func (s *neumaierSum) add(x float64) {
+	t := s.sum + x
+	if math.Abs(s.sum) >= math.Abs(x) {
+		s.c += (s.sum - t) + x
+	} else {
+		s.c += (x - t) + s.sum
+	}
+	s.sum = t
+}
+
+// merge adds the sum of o to s, keeping track of both compensation terms.
+func (s *neumaierSum) merge(o neumaierSum) {
+	s.add(o.sum)
+	s.add(o.c)
+}
+
+func (s *neumaierSum) value() float64 {
+	return s.sum + s.c
+}
+
+// NewFloatAccumulator returns a fresh FloatAccumulator initialized to zero.
+// The options configure the sharding of the FloatAccumulator.
+func NewFloatAccumulator(opts ...Option) *FloatAccumulator {
+	a := &FloatAccumulator{}
+	a.vs.opts.apply(opts)
+	return a
+}
+
+// Add adds n to the total.
+func (a *FloatAccumulator) Add(n float64) {
+	s := a.vs.Get()
+	s.mu.Lock()
+	s.sum.add(n)
+	s.mu.Unlock()
+}
+
+// Load computes the total value.
+func (a *FloatAccumulator) Load() float64 {
+	var total neumaierSum
+	a.vs.Range(func(s *floatAccumulatorShard) {
+		s.mu.Lock()
+		total.merge(s.sum)
+		s.mu.Unlock()
+	})
+	return total.value()
+}
+
+// Reset sets the total to zero and reports the old value.
+func (a *FloatAccumulator) Reset() float64 {
+	var total neumaierSum
+	a.vs.Range(func(s *floatAccumulatorShard) {
+		s.mu.Lock()
+		total.merge(s.sum)
+		s.sum = neumaierSum{}
+		s.mu.Unlock()
+	})
+	return total.value()
+}
+
+// String returns the total, so that FloatAccumulator implements expvar.Var.
+func (a *FloatAccumulator) String() string {
+	return strconv.FormatFloat(a.Load(), 'g', -1, 64)
+}
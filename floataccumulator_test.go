@@ -0,0 +1,59 @@
+package percpu
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestFloatAccumulatorPrecision(t *testing.T) {
+	a := NewFloatAccumulator(WithShards(1))
+	c := NewFloatCounter(WithShards(1))
+	a.Add(1)
+	c.Add(1)
+	for i := 0; i < 1000; i++ {
+		a.Add(1e-16)
+		c.Add(1e-16)
+	}
+	if got := c.Load(); got != 1 {
+		t.Fatalf("FloatCounter got %v; expected the tiny additions to be lost", got)
+	}
+	if got, want := a.Load(), 1+1e-13; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+}
+
+func TestFloatAccumulatorMerge(t *testing.T) {
+	setProcIDs(t, 3, 0, 1, 2)
+	a := NewFloatAccumulator()
+	a.Add(1e16)
+	a.Add(1)
+	a.Add(-1e16)
+	if got := a.Load(); got != 1 {
+		t.Fatalf("got %v; want 1", got)
+	}
+	if got := a.Reset(); got != 1 {
+		t.Fatalf("Reset returned %v; want 1", got)
+	}
+	if got := a.Load(); got != 0 {
+		t.Fatalf("got %v after Reset", got)
+	}
+}
+
+func TestFloatAccumulatorConcurrent(t *testing.T) {
+	a := NewFloatAccumulator()
+	var wg sync.WaitGroup
+	const n = 100
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < n; i++ {
+				a.Add(0.1)
+			}
+		}()
+	}
+	wg.Wait()
+	if got, want := a.Load(), 1000.0; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+}
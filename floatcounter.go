@@ -0,0 +1,80 @@
+package percpu
+
+import (
+	"math"
+	"strconv"
+	"sync/atomic"
+)
+
+// A FloatCounter is a float64 counter which may be efficiently incremented
+// by many goroutines concurrently. It is useful for quantities like seconds of
+// CPU time or costs.
+//
+// Each shard is updated with a compare-and-swap loop, which only retries when
+// goroutines race for the same shard. The shards are summed in the order used
+// by Range, so the total is subject to the usual floating-point rounding;
+// see FloatAccumulator for a more precise sum.
+//
+// Like Counter, Load and Reset do not observe a consistent view of the total
+// if they are called concurrently to Add. See Counter for the guarantees.
+type FloatCounter struct {
+	vs Values[atomicFloat64]
+}
+
+// atomicFloat64 is a float64 updated atomically through its bits.
+type atomicFloat64 struct {
+	bits atomic.Uint64
+}
+
+func (f *atomicFloat64) add(n float64) {
+	for {
+		old := f.bits.Load()
+		if f.bits.CompareAndSwap(old, math.Float64bits(math.Float64frombits(old)+n)) {
+			return
+		}
+	}
+}
+
+func (f *atomicFloat64) load() float64 {
+	return math.Float64frombits(f.bits.Load())
+}
+
+func (f *atomicFloat64) swap(n float64) float64 {
+	return math.Float64frombits(f.bits.Swap(math.Float64bits(n)))
+}
+
+// NewFloatCounter returns a fresh FloatCounter initialized to zero.
+// The options configure the sharding of the FloatCounter.
+func NewFloatCounter(opts ...Option) *FloatCounter {
+	c := &FloatCounter{}
+	c.vs.opts.apply(opts)
+	return c
+}
+
+// Add adds n to the total.
+func (c *FloatCounter) Add(n float64) {
+	c.vs.Get().add(n)
+}
+
+// Load computes the total counter value.
+func (c *FloatCounter) Load() float64 {
+	var sum float64
+	c.vs.Range(func(v *atomicFloat64) {
+		sum += v.load()
+	})
+	return sum
+}
+
+// Reset sets the counter to zero and reports the old value.
+func (c *FloatCounter) Reset() float64 {
+	var sum float64
+	c.vs.Range(func(v *atomicFloat64) {
+		sum += v.swap(0)
+	})
+	return sum
+}
+
+// String returns the total, so that FloatCounter implements expvar.Var.
+func (c *FloatCounter) String() string {
+	return strconv.FormatFloat(c.Load(), 'g', -1, 64)
+}
@@ -0,0 +1,54 @@
+package percpu
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestFloatCounter(t *testing.T) {
+	c := NewFloatCounter()
+	var wg sync.WaitGroup
+	const n = 100
+	var mu sync.Mutex
+	var resetSum float64
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < n; i++ {
+				c.Add(0.5)
+				if i%20 == 0 {
+					r := c.Reset()
+					mu.Lock()
+					resetSum += r
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	// Multiples of 0.5 in this range are exact, so there is no rounding.
+	if got, want := resetSum+c.Load(), float64(n*n)/2; got != want {
+		t.Fatalf("got total %v; want %v", got, want)
+	}
+}
+
+func TestFloatCounterShards(t *testing.T) {
+	setProcIDs(t, 2, 0, 1, 1)
+	c := NewFloatCounter()
+	c.Add(1.5)
+	c.Add(2.25)
+	c.Add(-0.25)
+	if got := c.Load(); got != 3.5 {
+		t.Fatalf("got %v; want 3.5", got)
+	}
+	if got := c.vs.Shard(1).load(); got != 2 {
+		t.Fatalf("got %v in shard 1; want 2", got)
+	}
+	if got := c.Reset(); got != 3.5 {
+		t.Fatalf("Reset returned %v; want 3.5", got)
+	}
+	if got := c.Load(); got != 0 {
+		t.Fatalf("got %v after Reset", got)
+	}
+}
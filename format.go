@@ -0,0 +1,68 @@
+package percpu
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// Format implements fmt.Formatter to help debugging.
+//
+// The %v verb prints the values of all shards in the order of their index,
+// like a slice. Shards that were not allocated yet print as a new shard would
+// be initialized: the zero value, or the value returned by the initializer of
+// NewValuesFunc. The %+v verb additionally prefixes each value with the label
+// of its shard (see WithShardLabels). Other verbs and flags are applied to
+// each value.
+//
+// Format reads the values without any synchronization, so it must not run
+// concurrently with code that modifies them, unless T synchronizes that
+// access itself.
+func (v *Values[T]) Format(f fmt.State, verb rune) {
+	indexed := verb == 'v' && f.Flag('+')
+	format := fmt.FormatString(f, verb)
+	var b strings.Builder
+	b.WriteByte('[')
+	v.rangeAllShards(func(i int, p *T) {
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+		if indexed {
+			b.WriteString(v.ShardLabel(i))
+			b.WriteByte(':')
+		}
+		fmt.Fprintf(&b, format, *p)
+	})
+	b.WriteByte(']')
+	f.Write([]byte(b.String()))
+}
+
+// Format implements fmt.Formatter.
+//
+// The verbs %s and %q format the result of String, and %+v prints the total
+// followed by the values of the individual shards, for example
+// "3 (shards: [1 0 2])". All other verbs format the total count like an int64.
+func (c *Counter) Format(f fmt.State, verb rune) {
+	var sum int64
+	var shards []int64
+	c.vs.rangeAllShards(func(_ int, v *atomic.Int64) {
+		n := v.Load()
+		sum += n
+		shards = append(shards, n)
+	})
+	switch {
+	case verb == 'v' && f.Flag('+'):
+		fmt.Fprintf(f, "%d (shards: %v)", sum, shards)
+	case verb == 's' || verb == 'q':
+		fmt.Fprintf(f, fmt.FormatString(f, verb), strconv.FormatInt(sum, 10))
+	default:
+		fmt.Fprintf(f, fmt.FormatString(f, verb), sum)
+	}
+}
+
+// String returns the total count in decimal. Together with Format, it makes
+// Counter an expvar.Var, so it can be passed to expvar.Publish directly.
+func (c *Counter) String() string {
+	return strconv.FormatInt(c.Load(), 10)
+}
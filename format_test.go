@@ -0,0 +1,93 @@
+package percpu
+
+import (
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestFormat(t *testing.T) {
+	setProcIDs(t, 3, 0, 2, 2, 2)
+
+	c := NewCounter()
+	c.Add(1)
+	c.Add(2)
+	c.Add(3)
+	var vs Values[int]
+	*vs.Get() = 7
+	var empty Values[int]
+
+	for _, tt := range []struct {
+		format string
+		arg    any
+		want   string
+	}{
+		{"%v", c, "6"},
+		{"%d", c, "6"},
+		{"%5d", c, "    6"},
+		{"%x", c, "6"},
+		{"%s", c, "6"},
+		{"%3s", c, "  6"},
+		{"%q", c, `"6"`},
+		{"%+v", c, "6 (shards: [1 0 5])"},
+		{"%v", &vs, "[0 0 7]"},
+		{"%+v", &vs, "[0:0 1:0 2:7]"},
+		{"%03d", &vs, "[000 000 007]"},
+		{"%v", &empty, "[]"},
+	} {
+		if got := fmt.Sprintf(tt.format, tt.arg); got != tt.want {
+			t.Errorf("Sprintf(%q) = %q; want %q", tt.format, got, tt.want)
+		}
+	}
+}
+
+func TestExpvar(t *testing.T) {
+	c := NewCounter()
+	c.Add(-3)
+	u := NewUintCounter()
+	u.Add(1 << 63)
+	c32 := NewCounter32()
+	c32.Add(4)
+	f := NewFloatCounter()
+	f.Add(1.5)
+	a := NewFloatAccumulator()
+	a.Add(1e-3)
+	g := NewGauge(GaugeMax)
+	g.Set(9)
+	d := NewDurationCounter()
+	d.Add(1500 * time.Millisecond)
+	bc := NewBoundedCounter(0, 10)
+	bc.Add(20)
+	maxT := NewMaxTracker()
+	maxT.Observe(-2)
+	minT := NewMinTracker()
+	for _, tt := range []struct {
+		v    expvar.Var
+		want string
+	}{
+		{c, "-3"},
+		{u, "9223372036854775808"},
+		{c32, "4"},
+		{f, "1.5"},
+		{a, "0.001"},
+		{g, "9"},
+		{d, "1500000000"},
+		{bc, "10"},
+		{maxT, "-2"},
+		{minT, "null"},
+	} {
+		got := tt.v.String()
+		if got != tt.want {
+			t.Errorf("%T: got %q; want %q", tt.v, got, tt.want)
+		}
+		if !json.Valid([]byte(got)) {
+			t.Errorf("%T: %q is not valid JSON", tt.v, got)
+		}
+	}
+	expvar.Publish("percpu_test_counter", c)
+	if got := expvar.Get("percpu_test_counter").String(); got != "-3" {
+		t.Fatalf("published counter is %q; want -3", got)
+	}
+}
@@ -0,0 +1,151 @@
+package percpu
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+)
+
+// GaugeAggregation selects how a Gauge merges the values of its shards.
+type GaugeAggregation uint8
+
+const (
+	// GaugeSum reports the sum of the shards, for example the total depth of
+	// several queues.
+	GaugeSum GaugeAggregation = iota
+	// GaugeMax reports the largest shard, for example the depth of the
+	// longest queue.
+	GaugeMax
+	// GaugeMin reports the smallest shard.
+	GaugeMin
+	// GaugeLast reports the shard that was set most recently, according to
+	// the Clock of the Gauge.
+	GaugeLast
+)
+
+// String returns the name of the aggregation.
+func (a GaugeAggregation) String() string {
+	switch a {
+	case GaugeSum:
+		return "sum"
+	case GaugeMax:
+		return "max"
+	case GaugeMin:
+		return "min"
+	case GaugeLast:
+		return "last"
+	default:
+		return fmt.Sprintf("GaugeAggregation(%d)", uint8(a))
+	}
+}
+
+// A Gauge is an int64 value that may be efficiently set by many goroutines
+// concurrently.
+//
+// Each shard holds the value last set on it. Load merges the shards using
+// the aggregation selected when creating the Gauge. Shards that were never
+// set do not take part in the aggregation.
+//
+// Load does not observe a consistent view of the shards if it is called
+// concurrently to Set.
+type Gauge struct {
+	agg GaugeAggregation
+	vs  Values[gaugeShard]
+}
+
+type gaugeShard struct {
+	mu  sync.Mutex
+	set bool
+	v   int64
+	// ts is the time of the last Set in nanoseconds, only in GaugeLast mode.
+	ts int64
+}
+
+// NewGauge returns a fresh Gauge using the given aggregation.
+// The options configure the sharding and, for GaugeLast, the Clock.
+func NewGauge(agg GaugeAggregation, opts ...Option) *Gauge {
+	g := &Gauge{agg: agg}
+	g.vs.opts.apply(opts)
+	return g
+}
+
+// Set sets the value of the current shard to v.
+func (g *Gauge) Set(v int64) {
+	var ts int64
+	if g.agg == GaugeLast {
+		ts = g.vs.opts.now().UnixNano()
+	}
+	s := g.vs.Get()
+	s.mu.Lock()
+	s.set = true
+	s.v = v
+	s.ts = ts
+	s.mu.Unlock()
+}
+
+// Add adds delta to the value of the current shard. A shard that was not set
+// yet starts at zero.
+//
+// With GaugeSum, Add makes the Gauge usable like an up-down counter, for
+// example of requests in flight: increments and decrements may land on
+// different shards, but their sum is right. With the other aggregations,
+// the merged value depends on which shards the updates land on, so Add is
+// mostly useful when the goroutines updating the Gauge keep to their shards.
+func (g *Gauge) Add(delta int64) {
+	var ts int64
+	if g.agg == GaugeLast {
+		ts = g.vs.opts.now().UnixNano()
+	}
+	s := g.vs.Get()
+	s.mu.Lock()
+	s.set = true
+	s.v += delta
+	s.ts = ts
+	s.mu.Unlock()
+}
+
+// Load merges the values of all shards.
+// It returns 0 if no shard was set.
+func (g *Gauge) Load() int64 {
+	return g.merge(false)
+}
+
+// Reset clears all shards, as if they were never set, and reports the merged
+// value Load would have returned.
+func (g *Gauge) Reset() int64 {
+	return g.merge(true)
+}
+
+func (g *Gauge) merge(reset bool) int64 {
+	var result, lastTS int64
+	first := true
+	g.vs.Range(func(s *gaugeShard) {
+		s.mu.Lock()
+		set, v, ts := s.set, s.v, s.ts
+		if reset {
+			s.set, s.v, s.ts = false, 0, 0
+		}
+		s.mu.Unlock()
+		if !set {
+			return
+		}
+		switch {
+		case first:
+			result, lastTS = v, ts
+		case g.agg == GaugeSum:
+			result += v
+		case g.agg == GaugeMax && v > result,
+			g.agg == GaugeMin && v < result,
+			g.agg == GaugeLast && ts > lastTS:
+			result, lastTS = v, ts
+		}
+		first = false
+	})
+	return result
+}
+
+// String returns the merged value in decimal, so that Gauge implements
+// expvar.Var.
+func (g *Gauge) String() string {
+	return strconv.FormatInt(g.Load(), 10)
+}
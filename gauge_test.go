@@ -0,0 +1,75 @@
+package percpu
+
+import (
+	"testing"
+	"time"
+)
+
+type stepClock struct {
+	now time.Time
+}
+
+func (c *stepClock) Now() time.Time {
+	c.now = c.now.Add(time.Second)
+	return c.now
+}
+
+func TestGauge(t *testing.T) {
+	for _, tt := range []struct {
+		agg  GaugeAggregation
+		want int64
+	}{
+		{GaugeSum, 14},
+		{GaugeMax, 7},
+		{GaugeMin, 2},
+		{GaugeLast, 5},
+	} {
+		t.Run(tt.agg.String(), func(t *testing.T) {
+			// Shard 3 is never set.
+			setProcIDs(t, 4, 0, 1, 2, 1)
+			g := NewGauge(tt.agg, WithClock(&stepClock{}))
+			if got := g.Load(); got != 0 {
+				t.Fatalf("got %d before Set; want 0", got)
+			}
+			g.Set(2)
+			g.Set(3)
+			g.Set(7)
+			g.Set(5)
+			if got := g.Load(); got != tt.want {
+				t.Fatalf("got %d; want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGaugeAdd(t *testing.T) {
+	setProcIDs(t, 2, 0, 1, 1, 0)
+	g := NewGauge(GaugeSum)
+	g.Add(1)
+	g.Add(1)
+	g.Add(-1)
+	g.Add(3)
+	if got := g.Load(); got != 4 {
+		t.Fatalf("got %d; want 4", got)
+	}
+	if got := g.vs.Shard(0).v; got != 4 {
+		t.Fatalf("got %d in shard 0; want 4", got)
+	}
+}
+
+func TestGaugeReset(t *testing.T) {
+	setProcIDs(t, 2, 0, 1, 0)
+	g := NewGauge(GaugeMin)
+	g.Set(5)
+	g.Set(-2)
+	if got := g.Reset(); got != -2 {
+		t.Fatalf("Reset returned %d; want -2", got)
+	}
+	if got := g.Load(); got != 0 {
+		t.Fatalf("got %d after Reset; want 0", got)
+	}
+	g.Set(7)
+	if got := g.Load(); got != 7 {
+		t.Fatalf("got %d; want the shards cleared by Reset to be ignored", got)
+	}
+}
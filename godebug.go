@@ -0,0 +1,47 @@
+package percpu
+
+import (
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Process-wide overrides of shard behavior, parsed from the GODEBUG
+// environment variable at startup.
+var (
+	// forcedShards is the number of shards every Values uses,
+	// zero if not forced.
+	forcedShards int
+	// pickerForced reports whether forcedPicker overrides the Picker of
+	// all Values.
+	pickerForced bool
+	forcedPicker Picker
+)
+
+func init() {
+	parseGODEBUG(os.Getenv("GODEBUG"))
+}
+
+// parseGODEBUG applies the settings recognized by the package from a GODEBUG
+// value. Unknown settings and invalid values are ignored, like the runtime
+// does.
+func parseGODEBUG(s string) {
+	for _, kv := range strings.Split(s, ",") {
+		k, v, ok := strings.Cut(kv, "=")
+		if !ok {
+			continue
+		}
+		switch k {
+		case "percpushards":
+			if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+				forcedShards = n
+			}
+		case "percpupicker":
+			for _, p := range pickers {
+				if v == p.String() {
+					pickerForced, forcedPicker = true, p
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package percpu
+
+import (
+	"testing"
+)
+
+func TestParseGODEBUG(t *testing.T) {
+	defer func(shards int, forced bool, p Picker) {
+		forcedShards, pickerForced, forcedPicker = shards, forced, p
+	}(forcedShards, pickerForced, forcedPicker)
+
+	for _, tt := range []struct {
+		godebug    string
+		wantShards int
+		wantForced bool
+		wantPicker Picker
+	}{
+		{"", 0, false, PickProc},
+		{"percpushards=1", 1, false, PickProc},
+		{"gctrace=1,percpushards=4,percpupicker=hash", 4, true, PickHash},
+		{"percpushards=-1,percpupicker=bogus", 0, false, PickProc},
+		{"percpupicker=hash,percpupicker=pin", 0, true, PickProc},
+		{"percpupicker=thread", 0, true, PickThread},
+		{"percpupicker=cpu", 0, true, PickCPU},
+	} {
+		forcedShards, pickerForced, forcedPicker = 0, false, PickProc
+		parseGODEBUG(tt.godebug)
+		if forcedShards != tt.wantShards || pickerForced != tt.wantForced || forcedPicker != tt.wantPicker {
+			t.Errorf("%q: got shards=%d forced=%v picker=%v; want shards=%d forced=%v picker=%v",
+				tt.godebug, forcedShards, pickerForced, forcedPicker, tt.wantShards, tt.wantForced, tt.wantPicker)
+		}
+	}
+}
+
+func TestForcedShards(t *testing.T) {
+	defer func(shards int) { forcedShards = shards }(forcedShards)
+	forcedShards = 2
+	var vs Values[int]
+	for i := 0; i < 100; i++ {
+		*vs.Get() += 1
+	}
+	var got []int
+	vs.rangeAllShards(func(_ int, p *int) { got = append(got, *p) })
+	if len(got) != 2 || got[0]+got[1] != 100 {
+		t.Fatalf("got shards %v; want 2 shards with total 100", got)
+	}
+}
+
+func TestHashProcID(t *testing.T) {
+	n := int(lastMaxProcs.Load())
+	for i := 0; i < 100; i++ {
+		if id := hashProcID(); id < 0 || id >= n {
+			t.Fatalf("got id %d out of range [0, %d)", id, n)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package percpu
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"unsafe"
+)
+
+// A ValueGroup stores the shards of several values together, to save memory
+// when there are many of them. Every shard of a Values is padded on both
+// sides, so a thousand Counters cost 2*CacheLineSize*GOMAXPROCS bytes of
+// padding each. A ValueGroup instead allocates one padded block per shard
+// that holds the shards of all values registered with the group with the same
+// index. Values in different shards still do not share cache lines, but
+// values of the group in the same shard do, so the group is meant for values
+// that are updated by the same goroutines anyway.
+//
+// Values are added to the group with Register. All values must be registered
+// before any of them is used.
+type ValueGroup struct {
+	mu     sync.Mutex
+	fields []reflect.StructField
+	sealed bool
+
+	once sync.Once
+	// typ is the type of the blocks, a struct with the registered values as
+	// fields, padded on both sides. It is set when the group is first used.
+	typ reflect.Type
+	// offsets holds the offset of each field of typ.
+	offsets []uintptr
+	blocks  *Values[unsafe.Pointer]
+	opts    []Option
+}
+
+// NewValueGroup returns an empty ValueGroup.
+// The options configure the sharding of the group.
+func NewValueGroup(opts ...Option) *ValueGroup {
+	return &ValueGroup{opts: opts}
+}
+
+// A GroupValue is a sharded value that is stored in a ValueGroup.
+type GroupValue[T any] struct {
+	g     *ValueGroup
+	field int
+}
+
+// Register adds a value of type T to g and returns it.
+// It panics if a value of g was already used.
+func Register[T any](g *ValueGroup) *GroupValue[T] {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.sealed {
+		panic("percpu: Register called after a value of the ValueGroup was used")
+	}
+	g.fields = append(g.fields, reflect.StructField{
+		Name: fmt.Sprintf("V%d", len(g.fields)),
+		Type: reflect.TypeOf((*T)(nil)).Elem(),
+	})
+	return &GroupValue[T]{g: g, field: len(g.fields)}
+}
+
+// seal builds the type of the blocks once the set of values is known.
+func (g *ValueGroup) seal() {
+	g.once.Do(func() {
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		g.sealed = true
+		pad := reflect.StructField{Name: "Pad1", Type: reflect.TypeOf(cacheLinePad{})}
+		fields := append([]reflect.StructField{pad}, g.fields...)
+		pad.Name = "Pad2"
+		fields = append(fields, pad)
+		g.typ = reflect.StructOf(fields)
+		for i := 0; i < g.typ.NumField(); i++ {
+			g.offsets = append(g.offsets, g.typ.Field(i).Offset)
+		}
+		g.blocks = NewValuesFunc(func(int) unsafe.Pointer {
+			return reflect.New(g.typ).UnsafePointer()
+		}, g.opts...)
+	})
+}
+
+// Get returns a pointer to one of the shards of v, like Values.Get.
+// All access of the returned value must use further synchronization
+// mechanisms.
+func (v *GroupValue[T]) Get() *T {
+	v.g.seal()
+	return v.at(*v.g.blocks.Get())
+}
+
+// Range runs fn on all shards of v, like Values.Range.
+func (v *GroupValue[T]) Range(fn func(p *T)) {
+	v.g.seal()
+	v.g.blocks.Range(func(block *unsafe.Pointer) {
+		fn(v.at(*block))
+	})
+}
+
+// at returns the value of v in block.
+func (v *GroupValue[T]) at(block unsafe.Pointer) *T {
+	return (*T)(unsafe.Add(block, v.g.offsets[v.field]))
+}
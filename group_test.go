@@ -0,0 +1,54 @@
+package percpu
+
+import (
+	"sync/atomic"
+	"testing"
+	"unsafe"
+
+	"github.com/martin-sucha/percpu/internal/hooks"
+)
+
+func TestValueGroup(t *testing.T) {
+	id := 0
+	restore := hooks.Update(func(h *hooks.Hooks) {
+		h.ProcID = func() int { return id }
+		h.MaxProcs = func() int { return 2 }
+	})
+	defer restore()
+	g := NewValueGroup()
+	requests := Register[atomic.Int64](g)
+	names := Register[[]string](g)
+	requests.Get().Add(1)
+	*names.Get() = append(*names.Get(), "a")
+	id = 1
+	requests.Get().Add(2)
+	*names.Get() = append(*names.Get(), "b")
+
+	var sum int64
+	requests.Range(func(p *atomic.Int64) { sum += p.Load() })
+	if sum != 3 {
+		t.Fatalf("got sum %d; want 3", sum)
+	}
+	var all []string
+	names.Range(func(p *[]string) { all = append(all, *p...) })
+	if len(all) != 2 || all[0] != "a" || all[1] != "b" {
+		t.Fatalf("got names %v; want [a b]", all)
+	}
+
+	// Values of one shard share a block.
+	a, b := uintptr(unsafe.Pointer(requests.Get())), uintptr(unsafe.Pointer(names.Get()))
+	if d := b - a; d >= CacheLineSize {
+		t.Errorf("values of one shard are %d bytes apart", d)
+	}
+	id = 0
+	if a0 := uintptr(unsafe.Pointer(requests.Get())); a0-a < CacheLineSize || a-a0 < CacheLineSize {
+		t.Errorf("values of different shards are closer than a cache line")
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("Register after use did not panic")
+		}
+	}()
+	Register[int](g)
+}
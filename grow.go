@@ -0,0 +1,52 @@
+package percpu
+
+import (
+	"sync/atomic"
+)
+
+// GrowEvent describes a Values growing its set of shards, or releasing
+// shards in Compact.
+type GrowEvent struct {
+	// OldShards is the number of shards before growing.
+	OldShards int
+	// NewShards is the number of shards after growing. It is lower than
+	// OldShards after Compact.
+	NewShards int
+	// ShardID is the ID of the shard whose access triggered the growth,
+	// or -1 for Compact.
+	ShardID int
+}
+
+var growHook atomic.Pointer[func(GrowEvent)]
+
+// SetGrowHook sets a function that is called whenever any Values in the process
+// grows its set of shards, which happens on first use and after GOMAXPROCS
+// increases, and when Compact releases shards. It allows correlating latency with the allocations done by
+// growing, for example by emitting trace events.
+//
+// fn is called synchronously by the Get call that grew the shards, so it must
+// be fast. A nil fn removes the hook.
+func SetGrowHook(fn func(GrowEvent)) {
+	if fn == nil {
+		growHook.Store(nil)
+		return
+	}
+	growHook.Store(&fn)
+}
+
+// notifyGrow reports e to the logger, the grow hook and onGrow, which is the
+// OnGrow callback of the Values that grew, or nil. l is the logger option of
+// the Values, see logDebugTo.
+func notifyGrow(e GrowEvent, onGrow *func(oldN, newN int), l *atomic.Pointer[Logger]) {
+	if e.NewShards < e.OldShards {
+		logDebugTo(l, "percpu: shards compacted", "old", e.OldShards, "new", e.NewShards)
+	} else {
+		logDebugTo(l, "percpu: shards grew", "old", e.OldShards, "new", e.NewShards, "shard", e.ShardID)
+	}
+	if fn := growHook.Load(); fn != nil {
+		(*fn)(e)
+	}
+	if onGrow != nil {
+		(*onGrow)(e.OldShards, e.NewShards)
+	}
+}
@@ -0,0 +1,93 @@
+package percpu
+
+import (
+	"testing"
+
+	"github.com/martin-sucha/percpu/internal/hooks"
+)
+
+func TestGrowHook(t *testing.T) {
+	id := 0
+	restore := hooks.Update(func(h *hooks.Hooks) {
+		h.ProcID = func() int { return id }
+		h.MaxProcs = func() int { return 2 }
+	})
+	defer restore()
+	var events []GrowEvent
+	SetGrowHook(func(e GrowEvent) {
+		events = append(events, e)
+	})
+	defer SetGrowHook(nil)
+
+	var vs Values[int]
+	vs.Get()
+	vs.Get()
+	id = 4
+	vs.Get()
+	vs.Compact(func(dst, src *int) {})
+	want := []GrowEvent{
+		{OldShards: 0, NewShards: 2, ShardID: 0},
+		{OldShards: 2, NewShards: 5, ShardID: 4},
+		{OldShards: 5, NewShards: 2, ShardID: -1},
+	}
+	if len(events) != len(want) {
+		t.Fatalf("got events %v; want %v", events, want)
+	}
+	for i := range want {
+		if events[i] != want[i] {
+			t.Fatalf("got events %v; want %v", events, want)
+		}
+	}
+}
+
+func TestStats(t *testing.T) {
+	before := ReadStats()
+	restore := hooks.Update(func(h *hooks.Hooks) {
+		h.ProcID = func() int { return 0 }
+		h.MaxProcs = func() int { return 3 }
+	})
+	defer restore()
+	var vs Values[int]
+	vs.Get()
+	vs.Get()
+	after := ReadStats()
+	if d := after.Grows - before.Grows; d != 1 {
+		t.Fatalf("got %d grows; want 1", d)
+	}
+	// Only the shard that was used is allocated.
+	if d := after.ShardsAllocated - before.ShardsAllocated; d != 1 {
+		t.Fatalf("got %d shards allocated; want 1", d)
+	}
+}
+
+func TestOnGrow(t *testing.T) {
+	maxProcs, id := 2, 0
+	restore := hooks.Update(func(h *hooks.Hooks) {
+		h.ProcID = func() int { return id }
+		h.MaxProcs = func() int { return maxProcs }
+	})
+	defer restore()
+	var vs Values[int]
+	var got [][2]int
+	vs.OnGrow(func(oldN, newN int) { got = append(got, [2]int{oldN, newN}) })
+	vs.Get()
+	maxProcs, id = 4, 3
+	vs.Get()
+	maxProcs = 3
+	vs.Compact(func(dst, src *int) {})
+	want := [][2]int{{0, 2}, {2, 4}, {4, 3}}
+	if len(got) != len(want) {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("got %v; want %v", got, want)
+		}
+	}
+	vs.OnGrow(nil)
+	id = 5
+	vs.Get()
+	if len(got) != len(want) {
+		t.Fatalf("got %v after removing the callback", got)
+	}
+}
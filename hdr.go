@@ -0,0 +1,246 @@
+package percpu
+
+import (
+	"math"
+	"math/bits"
+	"sync/atomic"
+)
+
+// An HDRHistogram records int64 values, like latencies in microseconds, with
+// a fixed relative precision over a wide range, like HdrHistogram. It may be
+// efficiently updated by many goroutines concurrently, and answers quantile
+// queries like the 99th percentile on the merged counts.
+//
+// The range of values and the precision as a number of significant decimal
+// digits are set when creating the histogram. For example, a histogram of
+// latencies from 1µs to 100s with 3 significant digits distinguishes 1000µs
+// from 1001µs and 100.0s from 100.1s, and needs about 150 kilobytes for each
+// shard. With one significant digit less, it needs about 20 kilobytes.
+//
+// Each shard holds a count for every bucket, so RecordValue updates only
+// memory of the shard of the calling goroutine, without locking. Load merges
+// the counts of all shards.
+//
+// Like Counter, Load and Reset do not observe a consistent view of the
+// counts if they are called concurrently to RecordValue.
+//
+// An HDRHistogram must be created with NewHDRHistogram.
+type HDRHistogram struct {
+	l  *hdrLayout
+	vs *Values[[]atomic.Uint64]
+}
+
+// hdrLayout maps values to indexes of counts, like the HdrHistogram
+// reference implementation. The counts are organized in buckets of
+// subBucketCount sub-buckets, where each bucket covers twice the range of the
+// previous one with the same number of sub-buckets. The lower half of the
+// sub-buckets of each bucket but the first overlaps the previous bucket, so
+// they are not stored.
+type hdrLayout struct {
+	lowest, highest             int64
+	unitMagnitude               int
+	subBucketHalfCountMagnitude int
+	subBucketHalfCount          int
+	subBucketMask               int64
+	countsLen                   int
+}
+
+func newHDRLayout(lowest, highest int64, digits int) *hdrLayout {
+	largestSingleUnit := 2 * int64(math.Pow10(digits))
+	subBucketCountMagnitude := int(math.Ceil(math.Log2(float64(largestSingleUnit))))
+	l := &hdrLayout{
+		lowest:                      lowest,
+		highest:                     highest,
+		unitMagnitude:               63 - bits.LeadingZeros64(uint64(lowest)),
+		subBucketHalfCountMagnitude: subBucketCountMagnitude - 1,
+		subBucketHalfCount:          1 << (subBucketCountMagnitude - 1),
+	}
+	subBucketCount := int64(1) << subBucketCountMagnitude
+	l.subBucketMask = (subBucketCount - 1) << l.unitMagnitude
+
+	buckets := 1
+	for smallestUntrackable := subBucketCount << l.unitMagnitude; smallestUntrackable <= highest; buckets++ {
+		if smallestUntrackable > math.MaxInt64/2 {
+			buckets++
+			break
+		}
+		smallestUntrackable <<= 1
+	}
+	l.countsLen = (buckets + 1) * l.subBucketHalfCount
+	return l
+}
+
+// index returns the index of the count of v, which must be in range.
+func (l *hdrLayout) index(v int64) int {
+	pow2Ceiling := 64 - bits.LeadingZeros64(uint64(v|l.subBucketMask))
+	bucket := pow2Ceiling - l.unitMagnitude - (l.subBucketHalfCountMagnitude + 1)
+	subBucket := int(v >> (bucket + l.unitMagnitude))
+	return (bucket+1)<<l.subBucketHalfCountMagnitude + subBucket - l.subBucketHalfCount
+}
+
+// bounds returns the smallest value counted at index i, and the size of the
+// range of values counted there.
+func (l *hdrLayout) bounds(i int) (lowest, size int64) {
+	bucket := i>>l.subBucketHalfCountMagnitude - 1
+	subBucket := i&(l.subBucketHalfCount-1) + l.subBucketHalfCount
+	if bucket < 0 {
+		subBucket -= l.subBucketHalfCount
+		bucket = 0
+	}
+	shift := bucket + l.unitMagnitude
+	return int64(subBucket) << shift, int64(1) << shift
+}
+
+// NewHDRHistogram returns an HDRHistogram without observations, for values
+// from lowest to highest, with the given number of significant decimal
+// digits. lowest is the smallest value that is distinguished from zero.
+// The options configure the sharding of the histogram.
+// It panics if lowest is not positive, highest is smaller than 2*lowest, or
+// digits is not between 1 and 5.
+func NewHDRHistogram(lowest, highest int64, digits int, opts ...Option) *HDRHistogram {
+	if lowest < 1 || highest < 2*lowest || digits < 1 || digits > 5 {
+		panic("percpu: NewHDRHistogram with invalid range or precision")
+	}
+	l := newHDRLayout(lowest, highest, digits)
+	return &HDRHistogram{
+		l: l,
+		vs: NewValuesFunc(func(int) []atomic.Uint64 {
+			return paddedSlice[atomic.Uint64](l.countsLen)
+		}, opts...),
+	}
+}
+
+// RecordValue records v. Values outside of the range of the histogram are
+// clamped to it, negative values are recorded as zero.
+func (h *HDRHistogram) RecordValue(v int64) {
+	h.RecordValues(v, 1)
+}
+
+// RecordValues records n occurrences of v, like calling RecordValue n times.
+func (h *HDRHistogram) RecordValues(v int64, n uint64) {
+	switch {
+	case v < 0:
+		v = 0
+	case v > h.l.highest:
+		v = h.l.highest
+	}
+	(*h.vs.Get())[h.l.index(v)].Add(n)
+}
+
+// Load merges the counts of all shards.
+func (h *HDRHistogram) Load() HDRSnapshot {
+	return h.merge(false)
+}
+
+// Reset sets all counts to zero and reports what Load would return.
+func (h *HDRHistogram) Reset() HDRSnapshot {
+	return h.merge(true)
+}
+
+func (h *HDRHistogram) merge(reset bool) HDRSnapshot {
+	s := HDRSnapshot{l: h.l, counts: make([]uint64, h.l.countsLen)}
+	h.vs.Range(func(counts *[]atomic.Uint64) {
+		for i := range *counts {
+			var n uint64
+			if reset {
+				n = (*counts)[i].Swap(0)
+			} else {
+				n = (*counts)[i].Load()
+			}
+			s.counts[i] += n
+			s.total += n
+		}
+	})
+	return s
+}
+
+// An HDRSnapshot holds the merged counts of an HDRHistogram.
+//
+// The values it reports are reconstructed from the buckets, so they are
+// accurate to the precision of the histogram.
+type HDRSnapshot struct {
+	l      *hdrLayout
+	counts []uint64
+	total  uint64
+}
+
+// Count returns the number of recorded values.
+func (s HDRSnapshot) Count() uint64 {
+	return s.total
+}
+
+// Quantile returns the value below which the fraction q of the recorded
+// values lie, for example the 99th percentile for q = 0.99. It returns the
+// largest value that is equivalent to the recorded value at that rank, to
+// the precision of the histogram, so the result is never below the exact
+// quantile. q is clamped to the range from 0 to 1.
+// It returns 0 if no values were recorded.
+func (s HDRSnapshot) Quantile(q float64) int64 {
+	if s.total == 0 {
+		return 0
+	}
+	q = math.Max(0, math.Min(1, q))
+	rank := uint64(math.Ceil(q * float64(s.total)))
+	if rank == 0 {
+		rank = 1
+	}
+	var seen uint64
+	for i, n := range s.counts {
+		seen += n
+		if seen >= rank {
+			lowest, size := s.l.bounds(i)
+			return lowest + size - 1
+		}
+	}
+	return s.l.highest
+}
+
+// Quantiles returns the Quantile of each of qs.
+func (s HDRSnapshot) Quantiles(qs ...float64) []int64 {
+	values := make([]int64, len(qs))
+	for i, q := range qs {
+		values[i] = s.Quantile(q)
+	}
+	return values
+}
+
+// Min returns the smallest recorded value, to the precision of the histogram,
+// or zero if no values were recorded.
+func (s HDRSnapshot) Min() int64 {
+	for i, n := range s.counts {
+		if n != 0 {
+			lowest, _ := s.l.bounds(i)
+			return lowest
+		}
+	}
+	return 0
+}
+
+// Max returns the largest recorded value, to the precision of the histogram,
+// or zero if no values were recorded.
+func (s HDRSnapshot) Max() int64 {
+	for i := len(s.counts) - 1; i >= 0; i-- {
+		if s.counts[i] != 0 {
+			lowest, size := s.l.bounds(i)
+			return lowest + size - 1
+		}
+	}
+	return 0
+}
+
+// Mean returns the mean of the recorded values, to the precision of the
+// histogram, or zero if no values were recorded. Each value is taken as the
+// middle of its range of equivalent values.
+func (s HDRSnapshot) Mean() float64 {
+	if s.total == 0 {
+		return 0
+	}
+	var sum float64
+	for i, n := range s.counts {
+		if n != 0 {
+			lowest, size := s.l.bounds(i)
+			sum += float64(n) * (float64(lowest) + float64(size-1)/2)
+		}
+	}
+	return sum / float64(s.total)
+}
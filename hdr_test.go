@@ -0,0 +1,104 @@
+package percpu
+
+import (
+	"math"
+	"math/rand"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+)
+
+func TestHDRLayout(t *testing.T) {
+	l := newHDRLayout(1, 100e6, 3)
+	if got := l.countsLen * 8; got > 150<<10 {
+		t.Errorf("got %d bytes of counts", got)
+	}
+	// Every value maps to a range that contains it and is precise to three
+	// significant digits, and the ranges of consecutive indexes touch.
+	prevEnd := int64(0)
+	for i := 0; i < l.countsLen; i++ {
+		lowest, size := l.bounds(i)
+		if lowest != prevEnd {
+			t.Fatalf("index %d starts at %d; want %d", i, lowest, prevEnd)
+		}
+		if float64(size) > math.Max(1, float64(lowest)/1000) {
+			t.Fatalf("index %d: range of %d at %d is too coarse", i, size, lowest)
+		}
+		for _, v := range []int64{lowest, lowest + size - 1} {
+			if v <= l.highest {
+				if got := l.index(v); got != i {
+					t.Fatalf("index(%d) = %d; want %d", v, got, i)
+				}
+			}
+		}
+		prevEnd = lowest + size
+	}
+	if prevEnd <= l.highest {
+		t.Fatalf("counts end at %d; want more than %d", prevEnd, l.highest)
+	}
+}
+
+func TestHDRHistogram(t *testing.T) {
+	h := NewHDRHistogram(1, 100e6, 3)
+	r := rand.New(rand.NewSource(1))
+	values := make([]int64, 100000)
+	for i := range values {
+		values[i] = int64(math.Exp(r.NormFloat64()*2 + 8))
+	}
+	var wg sync.WaitGroup
+	for g := 0; g < 10; g++ {
+		wg.Add(1)
+		go func(part []int64) {
+			defer wg.Done()
+			for _, v := range part {
+				h.RecordValue(v)
+			}
+		}(values[g*10000 : (g+1)*10000])
+	}
+	wg.Wait()
+	sort.Slice(values, func(i, j int) bool { return values[i] < values[j] })
+
+	s := h.Load()
+	if s.Count() != uint64(len(values)) {
+		t.Fatalf("got count %d; want %d", s.Count(), len(values))
+	}
+	for _, q := range []float64{0, 0.5, 0.9, 0.99, 0.999, 1} {
+		rank := int(math.Ceil(q * float64(len(values))))
+		if rank == 0 {
+			rank = 1
+		}
+		exact := values[rank-1]
+		got := s.Quantile(q)
+		if got < exact || float64(got-exact) > math.Max(1, float64(exact)/1000) {
+			t.Errorf("Quantile(%v) = %d; exact %d", q, got, exact)
+		}
+	}
+	if got, want := s.Quantiles(0, 1), []int64{s.Min(), s.Max()}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got min and max quantiles %v; want %v", got, want)
+	}
+	var sum float64
+	for _, v := range values {
+		sum += float64(v)
+	}
+	if mean := sum / float64(len(values)); math.Abs(s.Mean()-mean) > mean/1000 {
+		t.Errorf("got mean %v; want %v", s.Mean(), mean)
+	}
+
+	if r := h.Reset(); r.Count() != s.Count() {
+		t.Fatalf("Reset returned count %d; want %d", r.Count(), s.Count())
+	}
+	if s := h.Load(); s.Count() != 0 || s.Quantile(0.5) != 0 || s.Max() != 0 {
+		t.Fatalf("got count %d after Reset", s.Count())
+	}
+}
+
+func TestHDRHistogramClamp(t *testing.T) {
+	h := NewHDRHistogram(1, 1000, 2)
+	h.RecordValue(-5)
+	h.RecordValues(1e6, 3)
+	s := h.Load()
+	if s.Count() != 4 || s.Min() != 0 || s.Max() < 1000 {
+		t.Fatalf("got count %d, min %d, max %d", s.Count(), s.Min(), s.Max())
+	}
+}
@@ -0,0 +1,46 @@
+package percpu
+
+// ShardHeat reports how often a shard was accessed.
+type ShardHeat struct {
+	// Shard is the index of the shard.
+	Shard int
+	// Hits is the number of Get calls that returned the shard.
+	Hits uint64
+	// Migrations is the number of Update calls that started on the shard
+	// and during which the goroutine moved to another shard.
+	Migrations uint64
+}
+
+// Heat reports the number of Get calls served by each shard of v, and how
+// often a goroutine migrated away from each shard during Update.
+// Comparing the hits tells whether goroutines are actually spread across
+// shards or whether most of them funnel through a few. Many migrations tell
+// that the updates often land on the shard of another processor and contend
+// with its goroutines.
+//
+// Migrations are only counted by Update, because a caller of Get does not
+// tell when it is done with the value. Pin and RunPinned never migrate.
+//
+// Counting costs an atomic increment per Get and an extra shard lookup per
+// Update, so it is only enabled when the package is built with the
+// percpudebug build tag:
+//
+//	go build -tags percpudebug
+//
+// Without the build tag, Heat returns nil.
+func (v *Values[T]) Heat() []ShardHeat {
+	if !debugEnabled {
+		return nil
+	}
+	var heat []ShardHeat
+	v.rangeSlots(func(i int, slot *padded[T]) bool {
+		h := ShardHeat{Shard: i}
+		if slot != nil {
+			h.Hits = slot.heat.load()
+			h.Migrations = slot.heat.loadMigrations()
+		}
+		heat = append(heat, h)
+		return true
+	})
+	return heat
+}
@@ -0,0 +1,49 @@
+package percpu
+
+import (
+	"testing"
+)
+
+func TestHeat(t *testing.T) {
+	var vs Values[int]
+	if heat := vs.Heat(); heat != nil {
+		t.Fatalf("got heat %v for empty Values", heat)
+	}
+	setProcIDs(t, 0, 1, 1, 0, 1)
+	for i := 0; i < 4; i++ {
+		vs.Get()
+	}
+	heat := vs.Heat()
+	if !debugEnabled {
+		if heat != nil {
+			t.Fatalf("got heat %v without percpudebug", heat)
+		}
+		return
+	}
+	if len(heat) < 2 || heat[0].Hits != 1 || heat[1].Hits != 3 {
+		t.Fatalf("got heat %v; want 1 hit for shard 0 and 3 for shard 1", heat)
+	}
+}
+
+func TestMigrations(t *testing.T) {
+	if !debugEnabled {
+		t.Skip("migrations are only counted with percpudebug")
+	}
+	// Each Update looks up the shard before and after fn.
+	setProcIDs(t, 0, 0, 0, 0, 1, 1, 1)
+	before := ReadStats()
+	var vs Values[int]
+	for i := 0; i < 3; i++ {
+		vs.Update(func(p *int) { *p++ })
+	}
+	if got := ReadStats().Migrations - before.Migrations; got != 1 {
+		t.Fatalf("got %d migrations in stats; want 1", got)
+	}
+	heat := vs.Heat()
+	if len(heat) < 2 || heat[0].Migrations != 1 || heat[1].Migrations != 0 {
+		t.Fatalf("got heat %v; want 1 migration from shard 0", heat)
+	}
+	if heat[0].Hits != 2 || heat[1].Hits != 1 {
+		t.Fatalf("got heat %v; want 2 hits for shard 0 and 1 for shard 1", heat)
+	}
+}
@@ -0,0 +1,209 @@
+package percpu
+
+import (
+	"math"
+	"sort"
+)
+
+// A Histogram counts observed float64 values, like request latencies, in
+// buckets with fixed upper bounds. It may be efficiently updated by many
+// goroutines concurrently.
+//
+// Each shard holds a count for every bucket and the sum of the observed
+// values, so Observe updates only memory of the shard of the calling
+// goroutine. Load merges the counts of all shards into a HistogramSnapshot.
+//
+// Like Counter, Load and Reset do not observe a consistent view of the
+// counts if they are called concurrently to Observe. Reset does not lose
+// observations: each one is either reported by Reset or kept, though the
+// count and the value of a concurrent observation may be reported by
+// different calls.
+//
+// A Histogram must be created with NewHistogram.
+type Histogram struct {
+	bounds []float64
+	vs     *Values[histogramShard]
+}
+
+type histogramShard struct {
+	// counts holds the total weight of each bucket, the last one for values
+	// above the largest bound.
+	counts []atomicFloat64
+	sum    atomicFloat64
+}
+
+// HistogramSnapshot holds the merged counts of a Histogram.
+type HistogramSnapshot struct {
+	// Bounds holds the upper bounds of the buckets, in increasing order.
+	// Bucket i counts the values x with Bounds[i-1] < x <= Bounds[i].
+	// The snapshot shares Bounds with the Histogram, so it must not be
+	// modified.
+	Bounds []float64
+	// Counts holds the number of observations in each bucket, or their total
+	// weight if ObserveWeighted was used. It has one more element than
+	// Bounds, for the values above the largest bound.
+	Counts []float64
+	// Sum is the sum of the observed values, multiplied by their weights.
+	Sum float64
+}
+
+// Count returns the total number of observations, or their total weight if
+// ObserveWeighted was used.
+func (s HistogramSnapshot) Count() float64 {
+	var n float64
+	for _, c := range s.Counts {
+		n += c
+	}
+	return n
+}
+
+// Mean returns the mean of the observed values, or zero if there were none.
+func (s HistogramSnapshot) Mean() float64 {
+	n := s.Count()
+	if n == 0 {
+		return 0
+	}
+	return s.Sum / n
+}
+
+// Quantile returns an estimate of the value below which the fraction q of the
+// observed values lie, for example the 99th percentile for q = 0.99. q is
+// clamped to the range from 0 to 1.
+//
+// Like histogram_quantile of Prometheus, the estimate assumes the values are
+// spread uniformly within their bucket and interpolates linearly between the
+// bounds of the bucket that holds the rank q*Count. The lowest bucket is
+// assumed to start at zero if its bound is positive, and otherwise its bound
+// is returned. Ranks in the bucket above the largest bound return the largest
+// bound. It returns 0 if there were no observations or there are no bounds.
+func (s HistogramSnapshot) Quantile(q float64) float64 {
+	n := s.Count()
+	if n == 0 || len(s.Bounds) == 0 {
+		return 0
+	}
+	q = math.Max(0, math.Min(1, q))
+	rank := q * n
+	var before float64
+	for i, upper := range s.Bounds {
+		c := s.Counts[i]
+		if c == 0 || rank > before+c {
+			before += c
+			continue
+		}
+		var lower float64
+		switch {
+		case i > 0:
+			lower = s.Bounds[i-1]
+		case upper <= 0:
+			return upper
+		}
+		return lower + (upper-lower)*(rank-before)/c
+	}
+	return s.Bounds[len(s.Bounds)-1]
+}
+
+// Quantiles returns the Quantile of each of qs.
+func (s HistogramSnapshot) Quantiles(qs ...float64) []float64 {
+	values := make([]float64, len(qs))
+	for i, q := range qs {
+		values[i] = s.Quantile(q)
+	}
+	return values
+}
+
+// NewHistogram returns a Histogram without observations, with buckets
+// delimited by the given upper bounds. A bucket for the values above the
+// largest bound is added implicitly.
+// The options configure the sharding of the Histogram.
+// It panics if bounds are not in strictly increasing order or contain NaN.
+func NewHistogram(bounds []float64, opts ...Option) *Histogram {
+	for i, b := range bounds {
+		if math.IsNaN(b) || i > 0 && b <= bounds[i-1] {
+			panic("percpu: NewHistogram with bounds not in increasing order")
+		}
+	}
+	h := &Histogram{bounds: append([]float64(nil), bounds...)}
+	n := len(bounds) + 1
+	h.vs = NewValuesFunc(func(int) histogramShard {
+		return histogramShard{counts: paddedSlice[atomicFloat64](n)}
+	}, opts...)
+	return h
+}
+
+// Observe records x in the bucket with the smallest upper bound that is at
+// least x. NaN is counted in the last bucket.
+func (h *Histogram) Observe(x float64) {
+	h.ObserveWeighted(x, 1)
+}
+
+// ObserveWeighted records x with the given weight, as if x was observed
+// weight times, for example to record pre-aggregated values, or sampled values
+// with the inverse of their sampling probability. The weight does not need to
+// be an integer. Weights that are not positive and finite are ignored.
+func (h *Histogram) ObserveWeighted(x, weight float64) {
+	if !(weight > 0) || math.IsInf(weight, 0) {
+		return
+	}
+	i := sort.SearchFloat64s(h.bounds, x)
+	s := h.vs.Get()
+	s.counts[i].add(weight)
+	s.sum.add(x * weight)
+}
+
+// Load merges the counts of all shards.
+func (h *Histogram) Load() HistogramSnapshot {
+	return h.merge(false)
+}
+
+// Reset sets all counts to zero and reports what Load would return.
+func (h *Histogram) Reset() HistogramSnapshot {
+	return h.merge(true)
+}
+
+func (h *Histogram) merge(reset bool) HistogramSnapshot {
+	snap := HistogramSnapshot{
+		Bounds: h.bounds,
+		Counts: make([]float64, len(h.bounds)+1),
+	}
+	h.vs.Range(func(s *histogramShard) {
+		for i := range s.counts {
+			if reset {
+				snap.Counts[i] += s.counts[i].swap(0)
+			} else {
+				snap.Counts[i] += s.counts[i].load()
+			}
+		}
+		if reset {
+			snap.Sum += s.sum.swap(0)
+		} else {
+			snap.Sum += s.sum.load()
+		}
+	})
+	return snap
+}
+
+// LinearBounds returns n bucket bounds for NewHistogram, starting at start and
+// increasing by width.
+func LinearBounds(start, width float64, n int) []float64 {
+	bounds := make([]float64, n)
+	for i := range bounds {
+		bounds[i] = start + float64(i)*width
+	}
+	return bounds
+}
+
+// ExponentialBounds returns n bucket bounds for NewHistogram, starting at
+// start and multiplied by factor each. For example, ExponentialBounds(1e-3,
+// 2, 10) spans latencies from a millisecond to about half a second.
+// It panics unless start is positive and factor is greater than 1.
+func ExponentialBounds(start, factor float64, n int) []float64 {
+	if start <= 0 || factor <= 1 {
+		panic("percpu: ExponentialBounds with non-increasing bounds")
+	}
+	bounds := make([]float64, n)
+	for i := range bounds {
+		bounds[i] = start
+		start *= factor
+	}
+	return bounds
+}
@@ -0,0 +1,127 @@
+package percpu
+
+import (
+	"math"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestHistogram(t *testing.T) {
+	setProcIDs(t, 2, 0, 1, 0, 1, 0, 1)
+	h := NewHistogram([]float64{1, 2, 5})
+	for _, x := range []float64{0.5, 1, 1.5, 4, 5, 10} {
+		h.Observe(x)
+	}
+	got := h.Load()
+	if want := []float64{2, 1, 2, 1}; !reflect.DeepEqual(got.Counts, want) {
+		t.Fatalf("got counts %v; want %v", got.Counts, want)
+	}
+	if got.Sum != 22 || got.Count() != 6 {
+		t.Fatalf("got sum %v, count %v; want 22, 6", got.Sum, got.Count())
+	}
+	if got, want := got.Mean(), 22.0/6; got != want {
+		t.Fatalf("got mean %v; want %v", got, want)
+	}
+	if got := h.vs.Shard(1).counts[3].load(); got != 1 {
+		t.Fatalf("got %v in the last bucket of shard 1; want 1", got)
+	}
+	if r := h.Reset(); !reflect.DeepEqual(r, got) {
+		t.Fatalf("Reset returned %+v; want %+v", r, got)
+	}
+	if got := h.Load(); got.Count() != 0 || got.Sum != 0 || got.Mean() != 0 {
+		t.Fatalf("got %+v after Reset", got)
+	}
+}
+
+func TestHistogramObserveWeighted(t *testing.T) {
+	h := NewHistogram([]float64{1, 2}, WithShards(1))
+	h.ObserveWeighted(1.5, 2.5)
+	h.ObserveWeighted(5, 0)
+	h.ObserveWeighted(5, -1)
+	h.ObserveWeighted(5, math.Inf(1))
+	h.Observe(0.5)
+	got := h.Load()
+	if want := []float64{1, 2.5, 0}; !reflect.DeepEqual(got.Counts, want) {
+		t.Fatalf("got counts %v; want %v", got.Counts, want)
+	}
+	if got.Sum != 4.25 || got.Count() != 3.5 {
+		t.Fatalf("got sum %v, count %v; want 4.25, 3.5", got.Sum, got.Count())
+	}
+}
+
+func TestHistogramQuantile(t *testing.T) {
+	h := NewHistogram([]float64{1, 2, 5}, WithShards(1))
+	if got := h.Load().Quantile(0.5); got != 0 {
+		t.Fatalf("got %v without observations; want 0", got)
+	}
+	for _, x := range []float64{0.5, 1.5, 1.5, 4, 10} {
+		h.Observe(x)
+	}
+	s := h.Load()
+	for _, tt := range []struct {
+		q, want float64
+	}{
+		{-1, 0},
+		{0, 0},
+		{0.2, 1},
+		{0.3, 1.25},
+		{0.6, 2},
+		{0.7, 3.5},
+		{0.9, 5},
+		{2, 5},
+	} {
+		if got := s.Quantile(tt.q); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("Quantile(%v) = %v; want %v", tt.q, got, tt.want)
+		}
+	}
+	if qs := s.Quantiles(0.2, 0.6); !reflect.DeepEqual(qs, []float64{1, 2}) {
+		t.Errorf("got quantiles %v; want [1 2]", qs)
+	}
+
+	neg := NewHistogram([]float64{-1, 1}, WithShards(1))
+	neg.Observe(-5)
+	neg.Observe(0)
+	if got := neg.Load().Quantiles(0, 0.5, 0.75); !reflect.DeepEqual(got, []float64{-1, -1, 0}) {
+		t.Errorf("got quantiles %v with a negative bound; want [-1 -1 0]", got)
+	}
+}
+
+func TestHistogramConcurrent(t *testing.T) {
+	h := NewHistogram(LinearBounds(10, 10, 9))
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				h.Observe(float64(j + 1))
+			}
+		}()
+	}
+	wg.Wait()
+	got := h.Load()
+	for i, c := range got.Counts {
+		if c != 100 {
+			t.Fatalf("got %v in bucket %d; want 100: %v", c, i, got.Counts)
+		}
+	}
+}
+
+func TestBounds(t *testing.T) {
+	if got, want := LinearBounds(1, 2, 3), []float64{1, 3, 5}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("LinearBounds: got %v; want %v", got, want)
+	}
+	if got, want := ExponentialBounds(1, 2, 4), []float64{1, 2, 4, 8}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("ExponentialBounds: got %v; want %v", got, want)
+	}
+}
+
+func TestHistogramInvalidBounds(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("no panic for decreasing bounds")
+		}
+	}()
+	NewHistogram([]float64{1, 1})
+}
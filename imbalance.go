@@ -0,0 +1,67 @@
+package percpu
+
+import (
+	"math"
+)
+
+// Imbalance describes how evenly a quantity is spread across the shards of
+// a Values.
+//
+// A well-sharded workload has MaxOverMean close to 1 and
+// CoefficientOfVariation close to 0. If most of the load funnels through a
+// single shard, MaxOverMean approaches the number of shards.
+type Imbalance struct {
+	// Shards is the number of shards that were measured.
+	Shards int
+	// Max is the largest per-shard value.
+	Max float64
+	// Mean is the average per-shard value.
+	Mean float64
+	// MaxOverMean is Max divided by Mean, or 0 if Mean is 0.
+	MaxOverMean float64
+	// CoefficientOfVariation is the population standard deviation of
+	// the per-shard values divided by Mean, or 0 if Mean is 0.
+	CoefficientOfVariation float64
+}
+
+// MeasureImbalance computes the Imbalance of the values in v.
+// value converts a shard to the quantity that is measured, for example
+// the number of operations it has served.
+//
+// value is called as in v.Range, so the caller is responsible for
+// synchronizing access to the shards. Shards that were not allocated yet are
+// measured as the zero value of T.
+func MeasureImbalance[T any](v *Values[T], value func(p *T) float64) Imbalance {
+	var xs []float64
+	v.rangeAllShards(func(_ int, p *T) {
+		xs = append(xs, value(p))
+	})
+	return imbalanceOf(xs)
+}
+
+func imbalanceOf(xs []float64) Imbalance {
+	im := Imbalance{Shards: len(xs)}
+	if len(xs) == 0 {
+		return im
+	}
+	var sum float64
+	im.Max = math.Inf(-1)
+	for _, x := range xs {
+		sum += x
+		if x > im.Max {
+			im.Max = x
+		}
+	}
+	im.Mean = sum / float64(len(xs))
+	if im.Mean == 0 {
+		return im
+	}
+	var sqDiff float64
+	for _, x := range xs {
+		d := x - im.Mean
+		sqDiff += d * d
+	}
+	im.MaxOverMean = im.Max / im.Mean
+	im.CoefficientOfVariation = math.Sqrt(sqDiff/float64(len(xs))) / im.Mean
+	return im
+}
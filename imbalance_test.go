@@ -0,0 +1,46 @@
+package percpu
+
+import (
+	"math"
+	"testing"
+)
+
+func TestImbalanceOf(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		xs   []float64
+		want Imbalance
+	}{
+		{"empty", nil, Imbalance{}},
+		{"zeros", []float64{0, 0}, Imbalance{Shards: 2}},
+		{"even", []float64{5, 5, 5, 5}, Imbalance{Shards: 4, Max: 5, Mean: 5, MaxOverMean: 1}},
+		{"single", []float64{8, 0, 0, 0}, Imbalance{
+			Shards:                 4,
+			Max:                    8,
+			Mean:                   2,
+			MaxOverMean:            4,
+			CoefficientOfVariation: math.Sqrt(3),
+		}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got := imbalanceOf(tt.xs)
+			if got.Shards != tt.want.Shards || got.Max != tt.want.Max || got.Mean != tt.want.Mean ||
+				got.MaxOverMean != tt.want.MaxOverMean ||
+				math.Abs(got.CoefficientOfVariation-tt.want.CoefficientOfVariation) > 1e-9 {
+				t.Fatalf("got %+v; want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCounterImbalance(t *testing.T) {
+	c := NewCounter()
+	c.Add(10)
+	im := c.Imbalance()
+	if im.Shards == 0 || im.Max != 10 {
+		t.Fatalf("got %+v", im)
+	}
+	if want := float64(im.Shards); im.MaxOverMean != want {
+		t.Fatalf("got MaxOverMean %v; want %v", im.MaxOverMean, want)
+	}
+}
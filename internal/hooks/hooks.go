@@ -0,0 +1,57 @@
+// Package hooks allows the percputest package to alter the behavior of
+// the percpu package.
+//
+// Hooks are meant to be used in tests only.
+package hooks
+
+import (
+	"sync/atomic"
+)
+
+// Hooks overrides parts of the percpu implementation.
+// A nil field keeps the default behavior.
+type Hooks struct {
+	// ProcID returns the ID used for shard selection instead of the ID of
+	// the processor the calling goroutine runs on.
+	// It must return a non-negative integer.
+	ProcID func() int
+
+	// MaxProcs returns the value used in place of runtime.GOMAXPROCS(0)
+	// when sizing shards.
+	MaxProcs func() int
+
+	// Shards, if positive, forces the number of shards of every Values
+	// regardless of options, like the percpushards GODEBUG setting.
+	Shards int
+
+	// BeforeGrow is called when Values.Get has prepared a grown set of shards,
+	// right before it tries to publish it.
+	BeforeGrow func()
+
+	// AfterGet is called when Values.Get has resolved the shard, right before
+	// it returns the pointer to the caller.
+	AfterGet func()
+}
+
+var current atomic.Pointer[Hooks]
+
+// Load returns the currently installed hooks or nil if there are none.
+func Load() *Hooks {
+	return current.Load()
+}
+
+// Update installs hooks modified by fn and returns a function that restores
+// the hooks that were installed before.
+// fn receives a copy of the current hooks.
+func Update(fn func(h *Hooks)) (restore func()) {
+	old := current.Load()
+	var h Hooks
+	if old != nil {
+		h = *old
+	}
+	fn(&h)
+	current.Store(&h)
+	return func() {
+		current.Store(old)
+	}
+}
@@ -0,0 +1,41 @@
+//go:build go1.23
+
+package percpu
+
+import (
+	"iter"
+)
+
+// Copies returns an iterator over copies of all values in v, in the order
+// used by Range. copyFn is called on each shard to take the copy and must
+// synchronize with writers of the shard, for example by holding its lock or
+// by loading it atomically. Unlike Range, the iterator never exposes the
+// shared pointers to the loop body.
+func (v *Values[T]) Copies(copyFn func(p *T) T) iter.Seq[T] {
+	return func(yield func(T) bool) {
+		v.rangeSlots(func(_ int, slot *padded[T]) bool {
+			return slot == nil || yield(copyFn(&slot.v))
+		})
+	}
+}
+
+// All returns an iterator over all values in v. It visits the same values as
+// Range, with the same caveats, but the loop can stop early with break.
+func (v *Values[T]) All() iter.Seq[*T] {
+	return func(yield func(*T) bool) {
+		v.rangeSlots(func(_ int, slot *padded[T]) bool {
+			return slot == nil || yield(&slot.v)
+		})
+	}
+}
+
+// Indexed returns an iterator over all values in v together with the index of
+// their shard, in increasing order of the index.
+// It visits the same values as All.
+func (v *Values[T]) Indexed() iter.Seq2[int, *T] {
+	return func(yield func(int, *T) bool) {
+		v.rangeSlots(func(i int, slot *padded[T]) bool {
+			return slot == nil || yield(i, &slot.v)
+		})
+	}
+}
@@ -0,0 +1,67 @@
+//go:build go1.23
+
+package percpu
+
+import (
+	"testing"
+)
+
+func TestCopies(t *testing.T) {
+	setProcIDs(t, 3, 0, 1, 2)
+	var vs Values[[]int]
+	for i := 1; i <= 3; i++ {
+		*vs.Get() = []int{i}
+	}
+	clone := func(p *[]int) []int { return append([]int(nil), *p...) }
+	var got []int
+	for x := range vs.Copies(clone) {
+		x[0] *= 10 // must not modify the shard
+		got = append(got, x[0])
+	}
+	if len(got) != 3 || got[0] != 10 || got[1] != 20 || got[2] != 30 {
+		t.Fatalf("got %v; want [10 20 30]", got)
+	}
+	for x := range vs.Copies(clone) {
+		if x[0] != 1 {
+			t.Fatalf("got %d after break; want only 1", x[0])
+		}
+		break
+	}
+}
+
+func TestAll(t *testing.T) {
+	setProcIDs(t, 4, 0, 3)
+	var vs Values[int]
+	*vs.Get() = 1
+	*vs.Get() = 4
+	var got []int
+	for p := range vs.All() {
+		got = append(got, *p)
+	}
+	if len(got) != 2 || got[0] != 1 || got[1] != 4 {
+		t.Fatalf("got %v; want [1 4]", got)
+	}
+	for p := range vs.All() {
+		if *p != 1 {
+			t.Fatalf("got %d after break; want only 1", *p)
+		}
+		break
+	}
+}
+
+func TestIndexed(t *testing.T) {
+	setProcIDs(t, 4, 3, 1)
+	var vs Values[int]
+	*vs.Get() = 30
+	*vs.Get() = 10
+	var got []int
+	for i, p := range vs.Indexed() {
+		if *p != 10*i {
+			t.Fatalf("got %d at shard %d; want %d", *p, i, 10*i)
+		}
+		got = append(got, i)
+	}
+	if len(got) != 2 || got[0] != 1 || got[1] != 3 {
+		t.Fatalf("got shards %v; want [1 3]", got)
+	}
+}
@@ -0,0 +1,59 @@
+package percpu
+
+import (
+	"sync/atomic"
+)
+
+// A Logger receives internal events of the package, like shard growth.
+// The arguments are alternating keys and values.
+//
+// *slog.Logger satisfies the interface.
+type Logger interface {
+	Debug(msg string, args ...any)
+}
+
+var logger atomic.Pointer[Logger]
+
+// SetLogger sets the Logger that receives internal events of the package.
+// A nil l disables logging, which is the default.
+//
+// The Logger is called synchronously by the operation that caused the event,
+// so it must be fast.
+func SetLogger(l Logger) {
+	storeLogger(&logger, l)
+}
+
+// storeLogger stores l in p, or clears p if l is nil.
+func storeLogger(p *atomic.Pointer[Logger], l Logger) {
+	if l == nil {
+		p.Store(nil)
+		return
+	}
+	p.Store(&l)
+}
+
+// withLogger makes the Values log to the Logger in p if it holds one, and to
+// the package Logger otherwise. It is used by Registry.
+func withLogger(p *atomic.Pointer[Logger]) Option {
+	return func(o *options) {
+		o.logger = p
+	}
+}
+
+func logDebug(msg string, args ...any) {
+	if l := logger.Load(); l != nil {
+		(*l).Debug(msg, args...)
+	}
+}
+
+// logDebugTo logs to the Logger in p if it holds one, which may be nil, and
+// to the package Logger otherwise.
+func logDebugTo(p *atomic.Pointer[Logger], msg string, args ...any) {
+	if p != nil {
+		if l := p.Load(); l != nil {
+			(*l).Debug(msg, args...)
+			return
+		}
+	}
+	logDebug(msg, args...)
+}
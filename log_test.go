@@ -0,0 +1,87 @@
+package percpu
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+type recordingLogger struct {
+	lines []string
+}
+
+func (l *recordingLogger) Debug(msg string, args ...any) {
+	l.lines = append(l.lines, fmt.Sprint(append([]any{msg}, args...)...))
+}
+
+func TestLogger(t *testing.T) {
+	setProcIDs(t, 2, 0, 3)
+	l := &recordingLogger{}
+	SetLogger(l)
+	defer SetLogger(nil)
+
+	var vs Values[int]
+	vs.Get()
+	vs.Get()
+	vs.Compact(func(dst, src *int) {})
+	want := []string{
+		fmt.Sprint("percpu: shards grew", "old", 0, "new", 2, "shard", 0),
+		fmt.Sprint("percpu: shards grew", "old", 2, "new", 4, "shard", 3),
+		fmt.Sprint("percpu: shards compacted", "old", 4, "new", 2),
+	}
+	if len(l.lines) != len(want) {
+		t.Fatalf("got %q; want %q", l.lines, want)
+	}
+	for i := range want {
+		if l.lines[i] != want[i] {
+			t.Fatalf("got %q; want %q", l.lines, want)
+		}
+	}
+}
+
+// failingWriter is a ResponseWriter whose body cannot be written.
+type failingWriter struct {
+	*httptest.ResponseRecorder
+}
+
+func (failingWriter) Write([]byte) (int, error) {
+	return 0, errors.New("connection closed")
+}
+
+func TestRegistryLogger(t *testing.T) {
+	setProcIDs(t, 2, 0, 3)
+	pl := &recordingLogger{}
+	SetLogger(pl)
+	defer SetLogger(nil)
+	rl := &recordingLogger{}
+	var r Registry
+	r.SetLogger(rl)
+
+	c := r.NewCounter("requests")
+	c.Add(1)
+	c.Add(1)
+	r.ServeHTTP(failingWriter{httptest.NewRecorder()}, httptest.NewRequest(http.MethodGet, "/", nil))
+	want := fmt.Sprint(
+		[]string{
+			fmt.Sprint("percpu: shards grew", "old", 0, "new", 2, "shard", 0),
+			fmt.Sprint("percpu: shards grew", "old", 2, "new", 4, "shard", 3),
+			fmt.Sprint("percpu: writing registry failed", "err", errors.New("connection closed")),
+		},
+	)
+	if got := fmt.Sprint(rl.lines); got != want {
+		t.Fatalf("got %q in the Registry Logger; want %q", got, want)
+	}
+	if len(pl.lines) != 0 {
+		t.Fatalf("got %q in the package Logger; want nothing", pl.lines)
+	}
+
+	r.SetLogger(nil)
+	c.vs.Compact(func(dst, src *atomic.Int64) {})
+	want = fmt.Sprint([]string{fmt.Sprint("percpu: shards compacted", "old", 4, "new", 2)})
+	if got := fmt.Sprint(pl.lines); got != want {
+		t.Fatalf("got %q in the package Logger after SetLogger(nil); want %q", got, want)
+	}
+}
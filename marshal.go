@@ -0,0 +1,331 @@
+package percpu
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+)
+
+// The counters marshal as their total, and a Gauge as its merged value.
+// Unmarshaling resets the counter and adds the decoded total to a single
+// shard, like Counter.Store, so it must not run concurrently to other updates
+// of the counter.
+
+// MarshalText implements encoding.TextMarshaler.
+func (c *Counter) MarshalText() ([]byte, error) {
+	return strconv.AppendInt(nil, c.Load(), 10), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (c *Counter) UnmarshalText(text []byte) error {
+	n, err := strconv.ParseInt(string(text), 10, 64)
+	if err != nil {
+		return fmt.Errorf("percpu: invalid Counter value %q", text)
+	}
+	c.Store(n)
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler. The total is encoded as a number.
+func (c *Counter) MarshalJSON() ([]byte, error) {
+	return c.MarshalText()
+}
+
+// UnmarshalJSON implements json.Unmarshaler. Like with other
+// unmarshalers, null is a no-op.
+func (c *Counter) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+	return c.UnmarshalText(data)
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (c *UintCounter) MarshalText() ([]byte, error) {
+	return strconv.AppendUint(nil, c.Load(), 10), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (c *UintCounter) UnmarshalText(text []byte) error {
+	n, err := strconv.ParseUint(string(text), 10, 64)
+	if err != nil {
+		return fmt.Errorf("percpu: invalid UintCounter value %q", text)
+	}
+	c.Reset()
+	c.Add(n)
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler. The total is encoded as a number.
+func (c *UintCounter) MarshalJSON() ([]byte, error) {
+	return c.MarshalText()
+}
+
+// UnmarshalJSON implements json.Unmarshaler. Like with other
+// unmarshalers, null is a no-op.
+func (c *UintCounter) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+	return c.UnmarshalText(data)
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (c *Counter32) MarshalText() ([]byte, error) {
+	return strconv.AppendInt(nil, int64(c.Load()), 10), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (c *Counter32) UnmarshalText(text []byte) error {
+	n, err := strconv.ParseInt(string(text), 10, 32)
+	if err != nil {
+		return fmt.Errorf("percpu: invalid Counter32 value %q", text)
+	}
+	c.Reset()
+	c.Add(int32(n))
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler. The total is encoded as a number.
+func (c *Counter32) MarshalJSON() ([]byte, error) {
+	return c.MarshalText()
+}
+
+// UnmarshalJSON implements json.Unmarshaler. Like with other
+// unmarshalers, null is a no-op.
+func (c *Counter32) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+	return c.UnmarshalText(data)
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (c *FloatCounter) MarshalText() ([]byte, error) {
+	return strconv.AppendFloat(nil, c.Load(), 'g', -1, 64), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (c *FloatCounter) UnmarshalText(text []byte) error {
+	n, err := strconv.ParseFloat(string(text), 64)
+	if err != nil {
+		return fmt.Errorf("percpu: invalid FloatCounter value %q", text)
+	}
+	c.Reset()
+	c.Add(n)
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler. The total is encoded as a number.
+// It fails if the total is infinite or NaN, which JSON cannot represent.
+func (c *FloatCounter) MarshalJSON() ([]byte, error) {
+	return marshalJSONFloat(c.Load())
+}
+
+// UnmarshalJSON implements json.Unmarshaler. Like with other
+// unmarshalers, null is a no-op.
+func (c *FloatCounter) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+	return c.UnmarshalText(data)
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (a *FloatAccumulator) MarshalText() ([]byte, error) {
+	return strconv.AppendFloat(nil, a.Load(), 'g', -1, 64), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (a *FloatAccumulator) UnmarshalText(text []byte) error {
+	n, err := strconv.ParseFloat(string(text), 64)
+	if err != nil {
+		return fmt.Errorf("percpu: invalid FloatAccumulator value %q", text)
+	}
+	a.Reset()
+	a.Add(n)
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler. The total is encoded as a number.
+// It fails if the total is infinite or NaN, which JSON cannot represent.
+func (a *FloatAccumulator) MarshalJSON() ([]byte, error) {
+	return marshalJSONFloat(a.Load())
+}
+
+// UnmarshalJSON implements json.Unmarshaler. Like with other
+// unmarshalers, null is a no-op.
+func (a *FloatAccumulator) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+	return a.UnmarshalText(data)
+}
+
+// MarshalText implements encoding.TextMarshaler. The total is formatted like
+// time.Duration.String, for example "1m30s".
+func (d *DurationCounter) MarshalText() ([]byte, error) {
+	return []byte(d.Load().String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler. It accepts the formats
+// of time.ParseDuration.
+func (d *DurationCounter) UnmarshalText(text []byte) error {
+	dur, err := time.ParseDuration(string(text))
+	if err != nil {
+		return fmt.Errorf("percpu: invalid DurationCounter value %q", text)
+	}
+	d.c.Store(int64(dur))
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler. The total is encoded as a number of
+// nanoseconds, like encoding/json encodes a time.Duration.
+func (d *DurationCounter) MarshalJSON() ([]byte, error) {
+	return d.c.MarshalText()
+}
+
+// UnmarshalJSON implements json.Unmarshaler. Like with other
+// unmarshalers, null is a no-op.
+func (d *DurationCounter) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+	if err := d.c.UnmarshalText(data); err != nil {
+		return fmt.Errorf("percpu: invalid DurationCounter value %q", data)
+	}
+	return nil
+}
+
+// MarshalText implements encoding.TextMarshaler. The total is formatted as
+// a number of bytes, unlike String, so that it can be decoded exactly.
+func (b *BytesCounter) MarshalText() ([]byte, error) {
+	return b.c.MarshalText()
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (b *BytesCounter) UnmarshalText(text []byte) error {
+	n, err := strconv.ParseInt(string(text), 10, 64)
+	if err != nil {
+		return fmt.Errorf("percpu: invalid BytesCounter value %q", text)
+	}
+	b.c.Store(n)
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler. The total is encoded as a number.
+func (b *BytesCounter) MarshalJSON() ([]byte, error) {
+	return b.MarshalText()
+}
+
+// UnmarshalJSON implements json.Unmarshaler. Like with other
+// unmarshalers, null is a no-op.
+func (b *BytesCounter) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+	return b.UnmarshalText(data)
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (m *MonotonicCounter) MarshalText() ([]byte, error) {
+	return m.c.MarshalText()
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+// It fails for negative totals, which Add cannot produce.
+func (m *MonotonicCounter) UnmarshalText(text []byte) error {
+	n, err := strconv.ParseInt(string(text), 10, 64)
+	if err != nil || n < 0 {
+		return fmt.Errorf("percpu: invalid MonotonicCounter value %q", text)
+	}
+	m.c.Store(n)
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler. The total is encoded as a number.
+func (m *MonotonicCounter) MarshalJSON() ([]byte, error) {
+	return m.MarshalText()
+}
+
+// UnmarshalJSON implements json.Unmarshaler. Like with other
+// unmarshalers, null is a no-op.
+func (m *MonotonicCounter) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+	return m.UnmarshalText(data)
+}
+
+// MarshalText implements encoding.TextMarshaler. Whether the total saturated
+// is not encoded.
+func (c *BoundedCounter) MarshalText() ([]byte, error) {
+	total, _ := c.Load()
+	return strconv.AppendInt(nil, total, 10), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler. A total outside of
+// the bounds is clamped like by Add, and reported as saturated by Load.
+func (c *BoundedCounter) UnmarshalText(text []byte) error {
+	n, err := strconv.ParseInt(string(text), 10, 64)
+	if err != nil {
+		return fmt.Errorf("percpu: invalid BoundedCounter value %q", text)
+	}
+	c.Reset()
+	c.Add(n)
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler. The total is encoded as a number.
+func (c *BoundedCounter) MarshalJSON() ([]byte, error) {
+	return c.MarshalText()
+}
+
+// UnmarshalJSON implements json.Unmarshaler. Like with other
+// unmarshalers, null is a no-op.
+func (c *BoundedCounter) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+	return c.UnmarshalText(data)
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (g *Gauge) MarshalText() ([]byte, error) {
+	return strconv.AppendInt(nil, g.Load(), 10), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler. It resets the Gauge and
+// sets the decoded value on the current shard.
+func (g *Gauge) UnmarshalText(text []byte) error {
+	n, err := strconv.ParseInt(string(text), 10, 64)
+	if err != nil {
+		return fmt.Errorf("percpu: invalid Gauge value %q", text)
+	}
+	g.Reset()
+	g.Set(n)
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler. The merged value is encoded as
+// a number.
+func (g *Gauge) MarshalJSON() ([]byte, error) {
+	return g.MarshalText()
+}
+
+// UnmarshalJSON implements json.Unmarshaler. Like with other
+// unmarshalers, null is a no-op.
+func (g *Gauge) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+	return g.UnmarshalText(data)
+}
+
+func marshalJSONFloat(f float64) ([]byte, error) {
+	if math.IsInf(f, 0) || math.IsNaN(f) {
+		return nil, fmt.Errorf("percpu: cannot encode %v as JSON", f)
+	}
+	return strconv.AppendFloat(nil, f, 'g', -1, 64), nil
+}
@@ -0,0 +1,159 @@
+package percpu
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestMarshalJSON(t *testing.T) {
+	type state struct {
+		Requests Counter
+		Bytes    UintCounter
+		Errors   Counter32
+		Seconds  FloatCounter
+		Cost     FloatAccumulator
+		Missing  Counter
+	}
+	var s state
+	s.Requests.Add(-3)
+	s.Bytes.Add(1 << 63)
+	s.Errors.Add(2)
+	s.Seconds.Add(1.5)
+	s.Cost.Add(0.25)
+	s.Missing.Add(4)
+	data, err := json.Marshal(&s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const want = `{"Requests":-3,"Bytes":9223372036854775808,"Errors":2,"Seconds":1.5,"Cost":0.25,"Missing":4}`
+	if string(data) != want {
+		t.Fatalf("got %s; want %s", data, want)
+	}
+
+	var got state
+	got.Missing.Add(7)
+	if err := json.Unmarshal([]byte(`{"Requests":-3,"Bytes":9223372036854775808,"Errors":2,"Seconds":1.5,"Cost":0.25,"Missing":null}`), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Requests.Load() != -3 || got.Bytes.Load() != 1<<63 || got.Errors.Load() != 2 ||
+		got.Seconds.Load() != 1.5 || got.Cost.Load() != 0.25 || got.Missing.Load() != 7 {
+		t.Fatalf("got %+v", &got)
+	}
+
+	if err := json.Unmarshal([]byte(`{"Errors":4294967296}`), &got); err == nil {
+		t.Fatal("no error for a Counter32 out of range")
+	}
+	var f FloatCounter
+	f.Add(math.Inf(1))
+	if _, err := json.Marshal(&f); err == nil {
+		t.Fatal("no error for an infinite FloatCounter")
+	}
+}
+
+func TestMarshalText(t *testing.T) {
+	c := NewCounter()
+	c.Add(42)
+	text, err := c.MarshalText()
+	if err != nil || string(text) != "42" {
+		t.Fatalf("got %q, %v; want 42", text, err)
+	}
+	setProcIDs(t, 4, 0, 2)
+	d := NewCounter()
+	d.Add(5)
+	if err := d.UnmarshalText(text); err != nil {
+		t.Fatal(err)
+	}
+	if got := d.Load(); got != 42 {
+		t.Fatalf("got %d; want 42", got)
+	}
+	if got := d.vs.Shard(2).Load(); got != 42 {
+		t.Fatalf("got %d in shard 2; want the total in one shard", got)
+	}
+	if err := d.UnmarshalText([]byte("x")); err == nil {
+		t.Fatal("no error for invalid text")
+	}
+}
+
+func TestMarshalJSONWrappers(t *testing.T) {
+	type state struct {
+		Latency  *DurationCounter
+		Traffic  *BytesCounter
+		Requests *MonotonicCounter
+		Credits  *BoundedCounter
+		Depth    *Gauge
+	}
+	s := state{
+		Latency:  NewDurationCounter(),
+		Traffic:  NewBytesCounter(),
+		Requests: NewMonotonicCounter(),
+		Credits:  NewBoundedCounter(-10, 10),
+		Depth:    NewGauge(GaugeMax),
+	}
+	s.Latency.Add(1500 * time.Millisecond)
+	s.Traffic.Add(2048)
+	s.Requests.Add(3)
+	s.Credits.Add(-4)
+	s.Depth.Set(7)
+	data, err := json.Marshal(&s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const want = `{"Latency":1500000000,"Traffic":2048,"Requests":3,"Credits":-4,"Depth":7}`
+	if string(data) != want {
+		t.Fatalf("got %s; want %s", data, want)
+	}
+
+	got := state{
+		Latency:  NewDurationCounter(),
+		Traffic:  NewBytesCounter(),
+		Requests: NewMonotonicCounter(),
+		Credits:  NewBoundedCounter(-10, 10),
+		Depth:    NewGauge(GaugeMax),
+	}
+	got.Depth.Set(100)
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Latency.Load() != 1500*time.Millisecond || got.Traffic.Load() != 2048 ||
+		got.Requests.Load() != 3 || got.Depth.Load() != 7 {
+		t.Fatalf("got %s, %d, %d, %d", got.Latency.Load(), got.Traffic.Load(),
+			got.Requests.Load(), got.Depth.Load())
+	}
+	if n, sat := got.Credits.Load(); n != -4 || sat {
+		t.Fatalf("got credits %d, %v; want -4, false", n, sat)
+	}
+
+	if err := json.Unmarshal([]byte(`{"Credits":20}`), &got); err != nil {
+		t.Fatal(err)
+	}
+	if n, sat := got.Credits.Load(); n != 10 || !sat {
+		t.Fatalf("got credits %d, %v; want 10, true", n, sat)
+	}
+	if err := json.Unmarshal([]byte(`{"Requests":-1}`), &got); err == nil {
+		t.Fatal("no error for a negative MonotonicCounter")
+	}
+	if err := json.Unmarshal([]byte(`{"Latency":"1s"}`), &got); err == nil {
+		t.Fatal("no error for a DurationCounter string in JSON")
+	}
+}
+
+func TestMarshalTextDuration(t *testing.T) {
+	d := NewDurationCounter()
+	d.Add(90 * time.Second)
+	text, err := d.MarshalText()
+	if err != nil || string(text) != "1m30s" {
+		t.Fatalf("got %q, %v; want 1m30s", text, err)
+	}
+	var e DurationCounter
+	if err := e.UnmarshalText([]byte("1.5h")); err != nil {
+		t.Fatal(err)
+	}
+	if got := e.Load(); got != 90*time.Minute {
+		t.Fatalf("got %s; want 1h30m0s", got)
+	}
+	if err := e.UnmarshalText([]byte("5")); err == nil {
+		t.Fatal("no error for a duration without a unit")
+	}
+}
@@ -0,0 +1,103 @@
+package percpu
+
+import (
+	"sync"
+	"time"
+)
+
+// A Meter measures the rate of events, like the metered rates of the classic
+// metrics libraries: the overall mean rate and exponentially weighted moving
+// averages over 1, 5 and 15 minutes. It may be efficiently updated by many
+// goroutines concurrently.
+//
+// Mark only adds to a Counter, so it does not contend with other goroutines.
+// The moving averages are updated from the counted events when they are read,
+// like with EWMA, using the Clock of the Meter (see WithClock).
+type Meter struct {
+	c Counter
+
+	mu                   sync.Mutex
+	count                int64
+	rate1, rate5, rate15 ewmaRate
+	start, last          time.Time
+}
+
+// NewMeter returns a Meter without events.
+// The options configure the sharding of the counter and the Clock.
+func NewMeter(opts ...Option) *Meter {
+	m := &Meter{
+		rate1:  ewmaRate{tau: time.Minute.Seconds()},
+		rate5:  ewmaRate{tau: (5 * time.Minute).Seconds()},
+		rate15: ewmaRate{tau: (15 * time.Minute).Seconds()},
+	}
+	m.c.vs.opts.apply(opts)
+	m.start = m.c.vs.opts.now()
+	m.last = m.start
+	return m
+}
+
+// Mark records n events.
+func (m *Meter) Mark(n int64) {
+	m.c.Add(n)
+}
+
+// update folds the events counted since the last update into the averages
+// and returns the current time. m.mu must be held.
+func (m *Meter) update() time.Time {
+	now := m.c.vs.opts.now()
+	if dt := now.Sub(m.last).Seconds(); dt > 0 {
+		n := m.c.Reset()
+		m.count += n
+		m.rate1.update(n, dt)
+		m.rate5.update(n, dt)
+		m.rate15.update(n, dt)
+		m.last = now
+	}
+	return now
+}
+
+// Count returns the number of events recorded.
+func (m *Meter) Count() int64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.update()
+	return m.count + m.c.Load()
+}
+
+// Rate1 returns the moving average of events per second over one minute.
+func (m *Meter) Rate1() float64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.update()
+	return m.rate1.rate
+}
+
+// Rate5 returns the moving average of events per second over five minutes.
+func (m *Meter) Rate5() float64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.update()
+	return m.rate5.rate
+}
+
+// Rate15 returns the moving average of events per second over fifteen
+// minutes.
+func (m *Meter) Rate15() float64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.update()
+	return m.rate15.rate
+}
+
+// MeanRate returns the mean number of events per second since the Meter was
+// created, or zero if no time has passed.
+func (m *Meter) MeanRate() float64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := m.update()
+	elapsed := now.Sub(m.start).Seconds()
+	if elapsed <= 0 {
+		return 0
+	}
+	return float64(m.count+m.c.Load()) / elapsed
+}
@@ -0,0 +1,71 @@
+package percpu
+
+import (
+	"math"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMeter(t *testing.T) {
+	clock := &manualClock{now: time.Unix(0, 0)}
+	m := NewMeter(WithClock(clock))
+	if m.Count() != 0 || m.Rate1() != 0 || m.MeanRate() != 0 {
+		t.Fatal("got events initially")
+	}
+	// 5 events per second for 15 minutes, read every 5 seconds.
+	for i := 0; i < 180; i++ {
+		m.Mark(25)
+		clock.now = clock.now.Add(5 * time.Second)
+		m.Rate1()
+	}
+	if got := m.Count(); got != 4500 {
+		t.Fatalf("got count %d; want 4500", got)
+	}
+	if got := m.MeanRate(); got != 5 {
+		t.Fatalf("got mean rate %v; want 5", got)
+	}
+	// After 15 minutes, the 1 minute average has converged, and the others
+	// have reached 1-1/e^3 and 1-1/e of the rate.
+	for _, tt := range []struct {
+		name      string
+		got, want float64
+	}{
+		{"Rate1", m.Rate1(), 5},
+		{"Rate5", m.Rate5(), 5 * (1 - math.Exp(-3))},
+		{"Rate15", m.Rate15(), 5 * (1 - math.Exp(-1))},
+	} {
+		if math.Abs(tt.got-tt.want) > 0.01 {
+			t.Errorf("%s: got %v; want %v", tt.name, tt.got, tt.want)
+		}
+	}
+	// Without events, the averages decay and the mean rate drops.
+	clock.now = clock.now.Add(15 * time.Minute)
+	if got, want := m.Rate15(), 5*(1-math.Exp(-1))/math.E; math.Abs(got-want) > 0.01 {
+		t.Errorf("Rate15: got %v after 15 idle minutes; want %v", got, want)
+	}
+	if got := m.MeanRate(); got != 2.5 {
+		t.Errorf("got mean rate %v; want 2.5", got)
+	}
+}
+
+func TestMeterConcurrent(t *testing.T) {
+	m := NewMeter()
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				m.Mark(1)
+				if j%10 == 0 {
+					m.Rate1()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if got := m.Count(); got != 1000 {
+		t.Fatalf("got count %d; want 1000", got)
+	}
+}
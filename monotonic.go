@@ -0,0 +1,65 @@
+package percpu
+
+import "time"
+
+// A MonotonicCounter is an int64 counter which only goes up, like a count of
+// served requests exported as a Prometheus counter. It may be efficiently
+// incremented by many goroutines concurrently.
+//
+// Add panics if the delta is negative, so that a bug that decrements the
+// counter does not go unnoticed. As the shards only grow, a Load that starts
+// after another Load completed never returns a smaller total, even while
+// Adds run concurrently, unless the counter is reset in between or the total
+// overflows.
+//
+// Like Counter, Load and Reset do not observe a consistent view of the total
+// if they are called concurrently to Add. See Counter for the guarantees.
+type MonotonicCounter struct {
+	c Counter
+}
+
+// NewMonotonicCounter returns a fresh MonotonicCounter initialized to zero.
+// The options configure the sharding of the MonotonicCounter and its Clock.
+func NewMonotonicCounter(opts ...Option) *MonotonicCounter {
+	m := &MonotonicCounter{}
+	m.c.vs.opts.apply(opts)
+	return m
+}
+
+// Add adds n to the total count.
+// It panics if n is negative.
+func (m *MonotonicCounter) Add(n int64) {
+	if n < 0 {
+		panic("percpu: MonotonicCounter.Add with negative delta")
+	}
+	m.c.Add(n)
+}
+
+// Inc adds 1 to the total count.
+func (m *MonotonicCounter) Inc() {
+	m.c.Add(1)
+}
+
+// AddSince adds the number of nanoseconds elapsed since t, as measured by the
+// Clock of the MonotonicCounter (see WithClock).
+// It panics if t is in the future.
+func (m *MonotonicCounter) AddSince(t time.Time) {
+	m.Add(int64(m.c.vs.opts.now().Sub(t)))
+}
+
+// Load computes the total counter value.
+func (m *MonotonicCounter) Load() int64 {
+	return m.c.Load()
+}
+
+// Reset sets the counter to zero and reports the old value.
+// It is the only way to decrease the total.
+func (m *MonotonicCounter) Reset() int64 {
+	return m.c.Reset()
+}
+
+// String returns the total count in decimal, so that MonotonicCounter
+// implements expvar.Var.
+func (m *MonotonicCounter) String() string {
+	return m.c.String()
+}
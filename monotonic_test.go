@@ -0,0 +1,54 @@
+package percpu
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestMonotonicCounter(t *testing.T) {
+	m := NewMonotonicCounter()
+	var wg sync.WaitGroup
+	var stop atomic.Bool
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for !stop.Load() {
+				m.Inc()
+				m.Add(2)
+			}
+		}()
+	}
+	var last int64
+	for i := 0; i < 1000; i++ {
+		n := m.Load()
+		if n < last {
+			stop.Store(true)
+			wg.Wait()
+			t.Fatalf("Load decreased from %d to %d", last, n)
+		}
+		last = n
+	}
+	stop.Store(true)
+	wg.Wait()
+	if got := m.Reset(); got < last || got%3 != 0 {
+		t.Fatalf("Reset returned %d; want a multiple of 3 of at least %d", got, last)
+	}
+	if got := m.String(); got != "0" {
+		t.Fatalf("got %q after Reset; want 0", got)
+	}
+}
+
+func TestMonotonicCounterNegative(t *testing.T) {
+	m := NewMonotonicCounter()
+	defer func() {
+		if recover() == nil {
+			t.Fatal("no panic for a negative delta")
+		}
+		if got := m.Load(); got != 0 {
+			t.Fatalf("got %d; want the negative delta to be rejected", got)
+		}
+	}()
+	m.Add(-1)
+}
@@ -0,0 +1,21 @@
+//go:build !percpucheck
+
+package percpu
+
+import (
+	"time"
+)
+
+// checkEnabled reports whether the package is built with the percpucheck
+// build tag.
+const checkEnabled = false
+
+func checkProcID(shards int) int {
+	panic("percpu: checkProcID called without percpucheck build tag")
+}
+
+func watchPinned() *time.Timer {
+	return nil
+}
+
+func checkPinned(d time.Duration) {}
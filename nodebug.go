@@ -0,0 +1,23 @@
+//go:build !percpudebug
+
+package percpu
+
+// debugEnabled reports whether the package is built with the percpudebug
+// build tag.
+const debugEnabled = false
+
+// shardHeat is empty without the percpudebug build tag, so it costs neither
+// memory nor time.
+type shardHeat struct{}
+
+func (h *shardHeat) hit() {}
+
+func (h *shardHeat) migrate() {}
+
+func (h *shardHeat) load() uint64 {
+	return 0
+}
+
+func (h *shardHeat) loadMigrations() uint64 {
+	return 0
+}
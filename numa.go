@@ -0,0 +1,82 @@
+package percpu
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// RangeByNode runs fn once for each NUMA node with the values of the shards
+// of the CPUs on that node, in increasing order of the node number. This
+// allows aggregating values per node first, for example to avoid reading
+// shards across sockets on hot paths.
+//
+// The shards correspond to CPUs only if v selects shards with PickCPU, which
+// is the NUMA mode of Values. With other pickers, the nodes are meaningless.
+// The topology is read from sysfs on Linux; shards of CPUs whose node is
+// unknown, and all shards on other systems, are reported as node 0.
+//
+// The values are subject to the same caveats as in Range.
+func (v *Values[T]) RangeByNode(fn func(node int, ps []*T)) {
+	nodes := cpuNodes()
+	byNode := make(map[int][]*T)
+	v.RangeIndexed(func(cpu int, p *T) {
+		node := 0
+		if cpu < len(nodes) && nodes[cpu] >= 0 {
+			node = nodes[cpu]
+		}
+		byNode[node] = append(byNode[node], p)
+	})
+	ids := make([]int, 0, len(byNode))
+	for node := range byNode {
+		ids = append(ids, node)
+	}
+	sort.Ints(ids)
+	for _, node := range ids {
+		fn(node, byNode[node])
+	}
+}
+
+var (
+	cpuNodesOnce sync.Once
+	// cpuNodesCache maps CPU numbers to NUMA nodes, -1 means unknown.
+	cpuNodesCache []int
+)
+
+// cpuNodes returns the NUMA node of each CPU, by CPU number. It is nil if the
+// topology is not known.
+var cpuNodes = func() []int {
+	cpuNodesOnce.Do(func() {
+		cpuNodesCache = readCPUNodes()
+	})
+	return cpuNodesCache
+}
+
+// parseCPUList parses a list of CPU numbers in the format used by sysfs,
+// like "0-3,8,10-11".
+func parseCPUList(s string) ([]int, error) {
+	var cpus []int
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, nil
+	}
+	for _, r := range strings.Split(s, ",") {
+		lo, hi, isRange := strings.Cut(r, "-")
+		first, err := strconv.Atoi(lo)
+		if err != nil {
+			return nil, fmt.Errorf("percpu: invalid CPU list %q", s)
+		}
+		last := first
+		if isRange {
+			if last, err = strconv.Atoi(hi); err != nil || last < first {
+				return nil, fmt.Errorf("percpu: invalid CPU list %q", s)
+			}
+		}
+		for cpu := first; cpu <= last; cpu++ {
+			cpus = append(cpus, cpu)
+		}
+	}
+	return cpus, nil
+}
@@ -0,0 +1,38 @@
+package percpu
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// readCPUNodes reads the NUMA topology from sysfs.
+func readCPUNodes() []int {
+	dirs, err := filepath.Glob("/sys/devices/system/node/node[0-9]*")
+	if err != nil {
+		return nil
+	}
+	var nodes []int
+	for _, dir := range dirs {
+		node, err := strconv.Atoi(strings.TrimPrefix(filepath.Base(dir), "node"))
+		if err != nil {
+			continue
+		}
+		b, err := os.ReadFile(filepath.Join(dir, "cpulist"))
+		if err != nil {
+			continue
+		}
+		cpus, err := parseCPUList(string(b))
+		if err != nil {
+			continue
+		}
+		for _, cpu := range cpus {
+			for len(nodes) <= cpu {
+				nodes = append(nodes, -1)
+			}
+			nodes[cpu] = node
+		}
+	}
+	return nodes
+}
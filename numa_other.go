@@ -0,0 +1,8 @@
+//go:build !linux
+
+package percpu
+
+// readCPUNodes returns nil, as the NUMA topology is only known on Linux.
+func readCPUNodes() []int {
+	return nil
+}
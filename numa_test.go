@@ -0,0 +1,51 @@
+package percpu
+
+import (
+	"testing"
+)
+
+func TestParseCPUList(t *testing.T) {
+	got, err := parseCPUList("0-2,5,7-8\n")
+	want := []int{0, 1, 2, 5, 7, 8}
+	if err != nil || len(got) != len(want) {
+		t.Fatalf("got %v, %v; want %v", got, err, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("got %v; want %v", got, want)
+		}
+	}
+	for _, s := range []string{"a", "3-1", "1-x"} {
+		if _, err := parseCPUList(s); err == nil {
+			t.Errorf("%q: got no error", s)
+		}
+	}
+}
+
+func TestRangeByNode(t *testing.T) {
+	defer func(f func() []int) { cpuNodes = f }(cpuNodes)
+	cpuNodes = func() []int { return []int{1, 0, 1, -1} }
+	setProcIDs(t, 5, 0, 1, 2, 3, 4)
+	var vs Values[int]
+	for i := 0; i < 5; i++ {
+		*vs.Get() = i
+	}
+	got := map[int][]int{}
+	var order []int
+	vs.RangeByNode(func(node int, ps []*int) {
+		order = append(order, node)
+		for _, p := range ps {
+			got[node] = append(got[node], *p)
+		}
+	})
+	// CPUs 3 and 4 have no known node.
+	if len(order) != 2 || order[0] != 0 || order[1] != 1 {
+		t.Fatalf("got nodes %v; want [0 1]", order)
+	}
+	if g := got[0]; len(g) != 3 || g[0] != 1 || g[1] != 3 || g[2] != 4 {
+		t.Errorf("got node 0 shards %v; want [1 3 4]", g)
+	}
+	if g := got[1]; len(g) != 2 || g[0] != 0 || g[1] != 2 {
+		t.Errorf("got node 1 shards %v; want [0 2]", g)
+	}
+}
@@ -0,0 +1,61 @@
+package percpu
+
+// Number is a constraint that permits any integer or floating-point type.
+type Number interface {
+	~int | ~int8 | ~int16 | ~int32 | ~int64 |
+		~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64 | ~uintptr |
+		~float32 | ~float64
+}
+
+// The helpers below read the shards like Range does, without any
+// synchronization. The caller is responsible for synchronizing them with
+// concurrent writers.
+
+// Sum returns the sum of all values in v.
+func Sum[T Number](v *Values[T]) T {
+	var sum T
+	v.Range(func(p *T) {
+		sum += *p
+	})
+	return sum
+}
+
+// Max returns the largest value in v, or zero if no shard of v was used.
+func Max[T Number](v *Values[T]) T {
+	var max T
+	first := true
+	v.Range(func(p *T) {
+		if first || *p > max {
+			max = *p
+			first = false
+		}
+	})
+	return max
+}
+
+// Min returns the smallest value in v, or zero if no shard of v was used.
+func Min[T Number](v *Values[T]) T {
+	var min T
+	first := true
+	v.Range(func(p *T) {
+		if first || *p < min {
+			min = *p
+			first = false
+		}
+	})
+	return min
+}
+
+// Avg returns the mean of the values in v, or zero if no shard of v was used.
+func Avg[T Number](v *Values[T]) float64 {
+	var sum float64
+	n := 0
+	v.Range(func(p *T) {
+		sum += float64(*p)
+		n++
+	})
+	if n == 0 {
+		return 0
+	}
+	return sum / float64(n)
+}
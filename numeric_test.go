@@ -0,0 +1,30 @@
+package percpu
+
+import (
+	"testing"
+)
+
+func TestNumericHelpers(t *testing.T) {
+	var empty Values[int]
+	if Sum(&empty) != 0 || Max(&empty) != 0 || Min(&empty) != 0 || Avg(&empty) != 0 {
+		t.Fatal("helpers on empty Values did not return zero")
+	}
+
+	setProcIDs(t, 4, 0, 1, 2, 3)
+	var vs Values[float64]
+	for _, x := range []float64{-1, 4, 0.5, 2.5} {
+		*vs.Get() = x
+	}
+	if got := Sum(&vs); got != 6 {
+		t.Errorf("Sum = %v; want 6", got)
+	}
+	if got := Max(&vs); got != 4 {
+		t.Errorf("Max = %v; want 4", got)
+	}
+	if got := Min(&vs); got != -1 {
+		t.Errorf("Min = %v; want -1", got)
+	}
+	if got := Avg(&vs); got != 1.5 {
+		t.Errorf("Avg = %v; want 1.5", got)
+	}
+}
@@ -0,0 +1,201 @@
+package percpu
+
+import (
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/martin-sucha/percpu/internal/hooks"
+)
+
+// An Option configures a Values or a type built on top of it, like Counter.
+type Option func(o *options)
+
+// options holds the configuration of a Values.
+// The zero value is the default configuration.
+type options struct {
+	// shards is the fixed number of shards, zero means GOMAXPROCS.
+	shards int
+	// maxShards caps the number of shards, zero means no cap.
+	maxShards int
+	picker    Picker
+	// clock is used by time-based types, nil means SystemClock.
+	clock Clock
+	// label names shards for exporters, nil means the shard index.
+	label func(shard int) string
+	// consistent makes reads of a Counter linearizable, see
+	// WithConsistentReads.
+	consistent bool
+	// logger receives the events of the Values in place of the package
+	// Logger if it holds one, nil means the package Logger, see
+	// Registry.SetLogger.
+	logger *atomic.Pointer[Logger]
+}
+
+// WithShards makes the Values use exactly n shards, regardless of GOMAXPROCS.
+// Processor IDs are mapped onto the shards modulo n.
+// It panics if n <= 0.
+func WithShards(n int) Option {
+	if n <= 0 {
+		panic("percpu: WithShards with non-positive n")
+	}
+	return func(o *options) {
+		o.shards = n
+	}
+}
+
+// WithMaxShards limits the Values to at most n shards. Unlike WithShards, the
+// Values uses fewer shards if GOMAXPROCS is lower than n. Processor IDs are
+// mapped onto the shards modulo n. This trades some contention for memory on
+// machines with many processors.
+// It panics if n <= 0.
+//
+// The percpushards GODEBUG setting takes precedence over WithMaxShards.
+func WithMaxShards(n int) Option {
+	if n <= 0 {
+		panic("percpu: WithMaxShards with non-positive n")
+	}
+	return func(o *options) {
+		o.maxShards = n
+	}
+}
+
+// WithPicker selects how the Values picks the shard for the calling goroutine.
+// The default is PickProc. The Pickers differ a lot in the cost they add to
+// each Get; PickThread in particular makes a system call every time.
+//
+// The percpupicker GODEBUG setting overrides the Picker of all Values.
+func WithPicker(p Picker) Option {
+	return func(o *options) {
+		o.picker = p
+	}
+}
+
+// WithClock sets the Clock used by types that depend on time.
+// The default is SystemClock. Values ignore the Clock.
+func WithClock(c Clock) Option {
+	return func(o *options) {
+		o.clock = c
+	}
+}
+
+// WithShardLabels sets a function that names shards, for example by the
+// worker that is expected to use them. The labels are included in per-shard
+// exports, like CounterSnapshot and the %+v format of Values.
+// By default, shards are labeled by their index.
+func WithShardLabels(label func(shard int) string) Option {
+	return func(o *options) {
+		o.label = label
+	}
+}
+
+func (o *options) apply(opts []Option) {
+	for _, opt := range opts {
+		opt(o)
+	}
+}
+
+// NewValues returns a new Values configured by opts.
+//
+// A zero Values is equivalent to one returned by NewValues without options.
+func NewValues[T any](opts ...Option) *Values[T] {
+	v := &Values[T]{}
+	v.opts.apply(opts)
+	return v
+}
+
+// NewValuesFunc returns a new Values configured by opts, whose shards start
+// with the value returned by init instead of the zero value. This is useful
+// for values that are not ready to use as a zero value, such as maps.
+//
+// init is called with the index of the shard when Get uses the shard for the
+// first time. It may be called more than once for the same shard if several
+// goroutines race to allocate it, in which case only one of the results is
+// kept. init may run while the calling goroutine is pinned by Pin, so it must
+// not block.
+func NewValuesFunc[T any](init func(shard int) T, opts ...Option) *Values[T] {
+	v := NewValues[T](opts...)
+	v.init = init
+	return v
+}
+
+func (o *options) now() time.Time {
+	if o.clock == nil {
+		return time.Now()
+	}
+	return o.clock.Now()
+}
+
+// ShardLabel returns the label of the shard with the given index, in the order
+// used by Range. See WithShardLabels.
+func (v *Values[T]) ShardLabel(shard int) string {
+	if v.opts.label == nil {
+		return strconv.Itoa(shard)
+	}
+	return v.opts.label(shard)
+}
+
+// shardCount returns the number of shards a Values should have.
+func (o *options) shardCount() int {
+	forced := forcedShardCount()
+	if n := o.shards; n > 0 && forced == 0 {
+		return n
+	}
+	var n int
+	if h := hooks.Load(); h != nil && h.MaxProcs != nil {
+		n = h.MaxProcs()
+	} else if forced > 0 {
+		return forced
+	} else {
+		n = gomaxprocs()
+	}
+	if max := o.maxShards; max > 0 && n > max {
+		return max
+	}
+	return n
+}
+
+// forcedShardCount returns the number of shards forced by the hooks or, if no
+// hooks are installed, by the percpushards GODEBUG setting. It returns zero if
+// the number of shards is not forced.
+func forcedShardCount() int {
+	if h := hooks.Load(); h != nil {
+		return h.Shards
+	}
+	return forcedShards
+}
+
+// shardID returns the index of the shard the calling goroutine should use.
+// hooked reports whether any hooks are installed, so that Get can skip its
+// fast path without loading the hooks again.
+func (o *options) shardID() (id int, hooked bool) {
+	h := hooks.Load()
+	if h != nil && h.ProcID != nil {
+		return o.mapProcID(h.ProcID()), true
+	}
+	if checkEnabled {
+		id = checkProcID(o.shardCount())
+	} else {
+		id = pick(o.picker)
+	}
+	return o.mapProcID(id), h != nil
+}
+
+// mapProcID maps a processor ID onto the shards of a Values.
+func (o *options) mapProcID(id int) int {
+	if forced := forcedShardCount(); forced > 0 {
+		return id % forced
+	}
+	return o.mapID(id)
+}
+
+// mapID maps a processor ID onto the configured number of shards.
+func (o *options) mapID(id int) int {
+	if o.shards > 0 {
+		return id % o.shards
+	}
+	if o.maxShards > 0 {
+		return id % o.maxShards
+	}
+	return id
+}
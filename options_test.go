@@ -0,0 +1,82 @@
+package percpu
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestWithShards(t *testing.T) {
+	setProcIDs(t, 8, 0, 4, 7, 2)
+	vs := NewValues[int](WithShards(3))
+	for i := 0; i < 4; i++ {
+		*vs.Get() += 1
+	}
+	var got []int
+	vs.Range(func(p *int) { got = append(got, *p) })
+	if len(got) != 3 || got[0] != 1 || got[1] != 2 || got[2] != 1 {
+		t.Fatalf("got shards %v; want [1 2 1]", got)
+	}
+}
+
+func TestWithMaxShards(t *testing.T) {
+	t.Run("capped", func(t *testing.T) {
+		setProcIDs(t, 8, 0, 4, 7, 2)
+		vs := NewValues[int](WithMaxShards(3))
+		for i := 0; i < 4; i++ {
+			*vs.Get() += 1
+		}
+		if got, want := fmt.Sprint(vs), "[1 2 1]"; got != want {
+			t.Fatalf("got shards %s; want %s", got, want)
+		}
+	})
+	t.Run("below cap", func(t *testing.T) {
+		setProcIDs(t, 2, 1)
+		vs := NewValues[int](WithMaxShards(3))
+		vs.Get()
+		if n := vs.NumShards(); n != 2 {
+			t.Fatalf("got %d shards; want 2", n)
+		}
+	})
+}
+
+func TestWithPicker(t *testing.T) {
+	for _, p := range pickers {
+		t.Run(p.String(), func(t *testing.T) {
+			c := NewCounter(WithPicker(p))
+			for i := 0; i < 100; i++ {
+				c.Add(1)
+			}
+			if n := c.Load(); n != 100 {
+				t.Fatalf("got %d; want 100", n)
+			}
+		})
+	}
+}
+
+func TestPickerString(t *testing.T) {
+	for p, want := range map[Picker]string{
+		PickProc:    "pin",
+		PickHash:    "hash",
+		PickThread:  "thread",
+		PickCPU:     "cpu",
+		Picker(200): "Picker(200)",
+	} {
+		if got := p.String(); got != want {
+			t.Errorf("got %q; want %q", got, want)
+		}
+	}
+}
+
+func TestNewValuesFunc(t *testing.T) {
+	setProcIDs(t, 2, 1, 1)
+	vs := NewValuesFunc(func(shard int) map[string]int {
+		return map[string]int{"shard": shard}
+	})
+	(*vs.Get())["hits"]++
+	if m := *vs.Get(); m["shard"] != 1 || m["hits"] != 1 {
+		t.Fatalf("got %v; want shard 1 with 1 hit", m)
+	}
+	if got, want := fmt.Sprint(vs), "[map[shard:0] map[hits:1 shard:1]]"; got != want {
+		t.Fatalf("got %q; want %q", got, want)
+	}
+}
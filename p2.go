@@ -0,0 +1,204 @@
+package percpu
+
+import (
+	"math"
+	"sort"
+	"sync"
+)
+
+// A P2Quantile estimates a single quantile of observed float64 values, like
+// the 99th percentile of latencies, in constant memory with the P² algorithm
+// of Jain and Chlamtac. It may be efficiently updated by many goroutines
+// concurrently. It is cheaper than TDigest or HDRHistogram when only one
+// quantile is needed, but its estimate is less precise, especially for
+// values with an irregular distribution.
+//
+// Each shard runs its own estimator, which tracks the minimum, the maximum,
+// the target quantile and the quantiles halfway to the extremes with five
+// markers. Together, the markers describe a piecewise linear approximation of
+// the distribution of the values observed on the shard. Load merges the
+// shards by finding the value at which the approximations, weighted by the
+// number of observations of each shard, reach the target quantile.
+//
+// Like Counter, Load and Reset do not observe a consistent view of the shards
+// if they are called concurrently to Observe.
+type P2Quantile struct {
+	q  float64
+	vs Values[p2Shard]
+}
+
+type p2Shard struct {
+	mu sync.Mutex
+	e  p2Estimator
+}
+
+// p2Estimator is a P² estimator of one quantile. Until it has five
+// observations, the markers are the sorted observations.
+type p2Estimator struct {
+	count int
+	// heights and positions of the markers. The positions are 1-based
+	// ranks among the observations.
+	h, n [5]float64
+	// desired positions of the markers and their increments.
+	np, dn [5]float64
+}
+
+func (e *p2Estimator) observe(q, x float64) {
+	if e.count < 5 {
+		e.h[e.count] = x
+		e.count++
+		sort.Float64s(e.h[:e.count])
+		if e.count == 5 {
+			e.n = [5]float64{1, 2, 3, 4, 5}
+			e.np = [5]float64{1, 1 + 2*q, 1 + 4*q, 3 + 2*q, 5}
+			e.dn = [5]float64{0, q / 2, q, (1 + q) / 2, 1}
+		}
+		return
+	}
+	e.count++
+	var k int
+	switch {
+	case x < e.h[0]:
+		e.h[0] = x
+	case x >= e.h[4]:
+		e.h[4] = x
+		k = 3
+	default:
+		for k = 0; x >= e.h[k+1]; k++ {
+		}
+	}
+	for i := k + 1; i < 5; i++ {
+		e.n[i]++
+	}
+	for i := range e.np {
+		e.np[i] += e.dn[i]
+	}
+	for i := 1; i < 4; i++ {
+		d := e.np[i] - e.n[i]
+		if d >= 1 && e.n[i+1]-e.n[i] > 1 || d <= -1 && e.n[i-1]-e.n[i] < -1 {
+			d = math.Copysign(1, d)
+			h := e.parabolic(i, d)
+			if !(e.h[i-1] < h && h < e.h[i+1]) {
+				j := i + int(d)
+				h = e.h[i] + d*(e.h[j]-e.h[i])/(e.n[j]-e.n[i])
+			}
+			e.h[i] = h
+			e.n[i] += d
+		}
+	}
+}
+
+// parabolic returns the height of marker i moved by d with the
+// piecewise-parabolic prediction of P².
+func (e *p2Estimator) parabolic(i int, d float64) float64 {
+	return e.h[i] + d/(e.n[i+1]-e.n[i-1])*
+		((e.n[i]-e.n[i-1]+d)*(e.h[i+1]-e.h[i])/(e.n[i+1]-e.n[i])+
+			(e.n[i+1]-e.n[i]-d)*(e.h[i]-e.h[i-1])/(e.n[i]-e.n[i-1]))
+}
+
+// markers returns the heights and positions of the markers in use.
+func (e *p2Estimator) markers() (h, n []float64) {
+	if e.count < 5 {
+		return e.h[:e.count], []float64{1, 2, 3, 4}[:e.count]
+	}
+	return e.h[:], e.n[:]
+}
+
+// rank returns the approximate number of observations lower than or equal
+// to v, interpolating linearly between the markers.
+func (e *p2Estimator) rank(v float64) float64 {
+	h, n := e.markers()
+	if len(h) == 0 || v < h[0] {
+		return 0
+	}
+	for i := 1; i < len(h); i++ {
+		if v < h[i] {
+			return n[i-1] + (n[i]-n[i-1])*(v-h[i-1])/(h[i]-h[i-1])
+		}
+	}
+	return float64(e.count)
+}
+
+// NewP2Quantile returns a P2Quantile without observations, estimating the
+// quantile q, for example 0.99 for the 99th percentile.
+// The options configure the sharding of the P2Quantile.
+// It panics unless 0 < q < 1.
+func NewP2Quantile(q float64, opts ...Option) *P2Quantile {
+	if !(q > 0 && q < 1) {
+		panic("percpu: NewP2Quantile with quantile outside of (0, 1)")
+	}
+	p := &P2Quantile{q: q}
+	p.vs.opts.apply(opts)
+	return p
+}
+
+// Observe records x. NaN is ignored.
+func (p *P2Quantile) Observe(x float64) {
+	if math.IsNaN(x) {
+		return
+	}
+	s := p.vs.Get()
+	s.mu.Lock()
+	s.e.observe(p.q, x)
+	s.mu.Unlock()
+}
+
+// Load returns the estimate of the quantile, or zero if there were no
+// observations.
+func (p *P2Quantile) Load() float64 {
+	return p.merge(false)
+}
+
+// Reset forgets all observations and reports what Load would return.
+func (p *P2Quantile) Reset() float64 {
+	return p.merge(true)
+}
+
+func (p *P2Quantile) merge(reset bool) float64 {
+	var es []p2Estimator
+	p.vs.Range(func(s *p2Shard) {
+		s.mu.Lock()
+		if s.e.count > 0 {
+			es = append(es, s.e)
+		}
+		if reset {
+			s.e = p2Estimator{}
+		}
+		s.mu.Unlock()
+	})
+	return p2Merge(es, p.q)
+}
+
+// p2Merge returns the value at which the sum of the ranks of es reaches the
+// rank of quantile q among all their observations, found by bisection.
+func p2Merge(es []p2Estimator, q float64) float64 {
+	if len(es) == 0 {
+		return 0
+	}
+	lo, hi := math.Inf(1), math.Inf(-1)
+	count := 0
+	for i := range es {
+		h, _ := es[i].markers()
+		lo = math.Min(lo, h[0])
+		hi = math.Max(hi, h[len(h)-1])
+		count += es[i].count
+	}
+	// The same rank as the desired position of the middle marker.
+	target := 1 + q*float64(count-1)
+	for i := 0; i < 100 && lo < hi; i++ {
+		mid := lo + (hi-lo)/2
+		if mid == lo || mid == hi {
+			break
+		}
+		var rank float64
+		for j := range es {
+			rank += es[j].rank(mid)
+		}
+		if rank < target {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+	return hi
+}
@@ -0,0 +1,65 @@
+package percpu
+
+import (
+	"math/rand"
+	"sort"
+	"sync"
+	"testing"
+)
+
+func TestP2Quantile(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		shards int
+	}{
+		{"single", 1},
+		{"sharded", 8},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, q := range []float64{0.5, 0.9, 0.99} {
+				p := NewP2Quantile(q, WithShards(tt.shards))
+				r := rand.New(rand.NewSource(1))
+				values := make([]float64, 100000)
+				for i := range values {
+					values[i] = r.NormFloat64()
+				}
+				var wg sync.WaitGroup
+				for g := 0; g < 10; g++ {
+					wg.Add(1)
+					go func(part []float64) {
+						defer wg.Done()
+						for _, v := range part {
+							p.Observe(v)
+						}
+					}(values[g*10000 : (g+1)*10000])
+				}
+				wg.Wait()
+				sort.Float64s(values)
+				got := p.Load()
+				if e := rankError(values, got, q); e > 0.005 {
+					t.Errorf("q %v: got %v, off by %v in rank", q, got, e)
+				}
+				if r := p.Reset(); r != got {
+					t.Errorf("q %v: Reset returned %v; want %v", q, r, got)
+				}
+				if got := p.Load(); got != 0 {
+					t.Errorf("q %v: got %v after Reset; want 0", q, got)
+				}
+			}
+		})
+	}
+}
+
+func TestP2QuantileFew(t *testing.T) {
+	setProcIDs(t, 2, 0, 0, 1)
+	p := NewP2Quantile(0.5)
+	if got := p.Load(); got != 0 {
+		t.Fatalf("got %v without observations; want 0", got)
+	}
+	p.Observe(1)
+	p.Observe(3)
+	p.Observe(2)
+	if got := p.Load(); got < 1.9 || got > 2.1 {
+		t.Fatalf("got median %v; want about 2", got)
+	}
+}
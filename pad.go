@@ -0,0 +1,10 @@
+//go:build !percpupad128
+
+package percpu
+
+import (
+	"golang.org/x/sys/cpu"
+)
+
+// cacheLinePad separates shards to prevent false sharing.
+type cacheLinePad = cpu.CacheLinePad
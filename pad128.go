@@ -0,0 +1,7 @@
+//go:build percpupad128
+
+package percpu
+
+// cacheLinePad separates shards to prevent false sharing. With the
+// percpupad128 build tag, it is 128 bytes on all architectures.
+type cacheLinePad struct{ _ [128]byte }
@@ -1,11 +1,54 @@
 // Package percpu provides best-effort CPU-local sharded values.
+//
+// # Checked mode
+//
+// Building with the percpucheck build tag enables a mode that helps to find
+// misuse of the package, at the cost of performance. It is meant to be
+// combined with the race detector:
+//
+//	go test -race -tags percpucheck ./...
+//
+// In checked mode, Get returns a random shard on every call. Code that uses
+// the returned values without proper synchronization relies on goroutines
+// rarely sharing a shard by chance; checked mode makes them collide so that
+// the race detector reports the problem. Pin panics if the pinned function
+// runs for too long.
+//
+// Random shards make races likely to be reported, but not reproducible. To
+// make every goroutine share a single shard instead, so that the race
+// detector sees all conflicting accesses on every run, run the tests with
+// GODEBUG=percpushards=1 (see below) or use percputest.SingleShard:
+//
+//	GODEBUG=percpushards=1 go test -race ./...
+//
+// # Pure Go mode
+//
+// By default, the package reads the ID of the current processor from the Go
+// runtime using go:linkname. Building with the purego build tag avoids
+// go:linkname for environments that forbid it. In pure Go mode, PickProc
+// selects shards like PickHash, so shards are not local to processors, and Pin
+// does not pin the goroutine.
+//
+// # Environment
+//
+// The GODEBUG environment variable can override the sharding of all Values in
+// the process, without code changes. The settings are read at startup:
+//
+//	percpushards=N  use exactly N shards; percpushards=1 disables sharding
+//	percpupicker=P  select shards by P for all Values: "pin" is PickProc,
+//	                "hash" is PickHash, "thread" is PickThread, "cpu" is
+//	                PickCPU
+//
+// For example:
+//
+//	GODEBUG=percpushards=1 ./server
 package percpu
 
 import (
-	"golang.org/x/sys/cpu"
-	"runtime"
+	"fmt"
+	"github.com/martin-sucha/percpu/internal/hooks"
+	"sort"
 	"sync/atomic"
-	_ "unsafe"
 )
 
 // Values is a sharded set of values which have an affinity for a particular
@@ -13,22 +56,44 @@ import (
 // value simultaneously from many goroutines.
 //
 // A zero value of a Values is ready to use.
-// Values must not be copied after first use.
+// Values must not be copied after first use; go vet reports copies.
+// Get and Range panic if they detect that the Values was copied.
+// Use Clone to make an independent copy.
 type Values[T any] struct {
-	pad1 cpu.CacheLinePad // prevent false sharing
+	_    noCopy
+	pad1 cacheLinePad // prevent false sharing
 
-	// shards keeps the per-CPU pointers.
+	// shards keeps the per-CPU slots.
 	// Grows in case GOMAXPROCS is increased.
 	// Never shrinks.
-	shards atomic.Pointer[[]*padded[T]]
+	// A slot is nil until its shard is used for the first time. The slots are
+	// shared by the old and the new slice when growing, so that a value
+	// allocated concurrently to the growth is not lost.
+	shards atomic.Pointer[[]*atomic.Pointer[padded[T]]]
+
+	// self points to the Values itself once it has been used.
+	// It detects copies.
+	self atomic.Pointer[Values[T]]
 
-	pad2 cpu.CacheLinePad // prevent false sharing
+	opts options
+
+	// init returns the initial value of a shard, nil means the zero value.
+	init func(shard int) T
+
+	// onGrow is called when the set of shards changes, see OnGrow.
+	onGrow atomic.Pointer[func(oldN, newN int)]
+
+	pad2 cacheLinePad // prevent false sharing
 }
 
 type padded[T any] struct {
-	pad1 cpu.CacheLinePad // prevent false sharing
-	v    T
-	pad2 cpu.CacheLinePad // prevent false sharing
+	pad1 cacheLinePad // prevent false sharing
+	// align64 aligns v to 64 bits even on 32-bit systems, where the compiler
+	// would otherwise only align padded to 32 bits.
+	align64 [0]atomic.Int64
+	v       T
+	heat    shardHeat
+	pad2    cacheLinePad // prevent false sharing
 }
 
 // Get returns a pointer to one of the values in v.
@@ -39,46 +104,217 @@ type padded[T any] struct {
 // All access of the returned value must use further synchronization
 // mechanisms.
 //
-// If a value for a given CPU does not exist yet, Values allocates a new zero value.
+// If a value for a given CPU does not exist yet, Values allocates a new zero value,
+// or the value returned by the init function passed to NewValuesFunc.
+// Values are allocated lazily: a shard does not use any memory for its value
+// until Get returns it for the first time.
 // The value is guaranteed to be allocated in a memory block
 // with sufficient padding to avoid false sharing.
-// Standard value alignment guarantees apply.
-// This means that the implementation does NOT guarantee that a 64-bit
-// integer will be aligned to the 64-bit boundary on 32-bit systems.
-// See also Bugs section in the documentation of sync/atomic.
+// The value is aligned to 64 bits, even on 32-bit systems, so a 64-bit
+// integer at the start of T can be accessed atomically with the functions of
+// sync/atomic. See also Bugs section in the documentation of sync/atomic.
 //
 // A pointer returned by Get will be observed by Range forever,
 // there isn't a way to free any of the values.
 func (v *Values[T]) Get() *T {
-	shardID := getProcID()
+	// Get must stay small enough to be inlined into its callers, see
+	// TestGetInlined.
+	return &v.current().v
+}
+
+// Update calls fn with the value returned by Get. The same synchronization
+// rules apply to fn as to the value returned by Get.
+//
+// In builds with the percpudebug build tag, Update looks up the shard again
+// after fn returns and counts a migration if the calling goroutine moved to
+// another shard in the meantime. See Heat and Stats.Migrations.
+func (v *Values[T]) Update(fn func(p *T)) {
+	if !debugEnabled {
+		fn(v.Get())
+		return
+	}
+	id, _ := v.opts.shardID()
+	slot := v.get(id)
+	fn(&slot.v)
+	if after, _ := v.opts.shardID(); after != id {
+		slot.heat.migrate()
+		stats.migrations.Add(1)
+	}
+}
+
+// current returns the shard of the calling goroutine. It holds the fast path
+// of Get, with the cheap shardID and lookup inlined, and only calls get to
+// grow the shards, allocate the value, detect a copy of v or run test hooks.
+func (v *Values[T]) current() *padded[T] {
+	id, hooked := v.opts.shardID()
+	if !hooked {
+		if slot := v.lookup(id); slot != nil {
+			return slot
+		}
+	}
+	return v.get(id)
+}
 
+// lookup is the fast path of current. It returns the shard with the given ID
+// if it is ready to use, or nil if get must be called to grow the shards,
+// allocate the value or detect a copy of v.
+// lookup must stay small enough to be inlined into current.
+func (v *Values[T]) lookup(shardID int) *padded[T] {
+	if shards := v.shards.Load(); shards != nil && shardID < len(*shards) && v.self.Load() == v {
+		if slot := (*shards)[shardID].Load(); slot != nil {
+			slot.heat.hit()
+			return slot
+		}
+	}
+	return nil
+}
+
+// noCopy makes go vet report copies of a Values, see the -copylocks checker.
+type noCopy struct{}
+
+func (*noCopy) Lock()   {}
+func (*noCopy) Unlock() {}
+
+// get returns the shard with the given ID, growing the shards if necessary.
+// The calling goroutine must not be pinned by Pin, because growing and
+// allocating the value may block and run the hooks and the init function.
+func (v *Values[T]) get(shardID int) *padded[T] {
+	shards := v.load()
+	if shards == nil || shardID >= len(*shards) {
+		shards = v.grow(shards, shardID)
+	}
+
+	slot := (*shards)[shardID].Load()
+	if slot == nil {
+		slot = v.allocate(shardID, (*shards)[shardID])
+	}
+	slot.heat.hit()
+	if h := hooks.Load(); h != nil && h.AfterGet != nil {
+		h.AfterGet()
+	}
+	return slot
+}
+
+// load returns the current shards, checking that v was not copied, or
+// marking v as used if it has no shards yet.
+func (v *Values[T]) load() *[]*atomic.Pointer[padded[T]] {
 	shards := v.shards.Load()
+	if shards != nil {
+		v.checkNotCopied()
+	} else {
+		v.self.CompareAndSwap(nil, v)
+	}
+	return shards
+}
+
+// grow grows the shards, which were loaded as shards, so that there is
+// a shard with the given ID, and returns the new shards.
+func (v *Values[T]) grow(shards *[]*atomic.Pointer[padded[T]], shardID int) *[]*atomic.Pointer[padded[T]] {
 	for shards == nil || shardID >= len(*shards) {
 		// GOMAXPROCS has changed or shards was not initialized.
-		newShardCount := runtime.GOMAXPROCS(0)
+		newShardCount := v.opts.shardCount()
 		if shardID >= newShardCount {
 			// GOMAXPROCS might be lower than shardID+1 if GOMAXPROCS increased and then decreased.
 			// Ensure we have enough space.
 			newShardCount = shardID + 1
 		}
-		newShards := make([]*padded[T], newShardCount)
+		newShards := make([]*atomic.Pointer[padded[T]], newShardCount)
 		nValid := 0
 		if shards != nil {
 			nValid = copy(newShards, *shards)
 		}
+		slots := make([]atomic.Pointer[padded[T]], newShardCount-nValid)
 		for i := nValid; i < newShardCount; i++ {
-			newShards[i] = new(padded[T])
+			newShards[i] = &slots[i-nValid]
 		}
 
+		if h := hooks.Load(); h != nil && h.BeforeGrow != nil {
+			h.BeforeGrow()
+		}
 		if v.shards.CompareAndSwap(shards, &newShards) {
-			shards = &newShards
-			break
+			stats.grows.Add(1)
+			e := GrowEvent{OldShards: nValid, NewShards: newShardCount, ShardID: shardID}
+			notifyGrow(e, v.onGrow.Load(), v.opts.logger)
+			return &newShards
 		}
 		// Another goroutine beat us, retry.
+		stats.growRetries.Add(1)
 		shards = v.shards.Load()
 	}
+	return shards
+}
+
+// Preallocate creates the shards for the current GOMAXPROCS and allocates
+// their values up front, so that the first Get on each processor does not
+// have to. It is meant to be called during startup by latency-sensitive
+// programs. Get still grows v if GOMAXPROCS increases later.
+func (v *Values[T]) Preallocate() {
+	shards := v.load()
+	if n := v.opts.shardCount(); shards == nil || n > len(*shards) {
+		// The event reports the last shard as the one that triggered the
+		// growth.
+		shards = v.grow(shards, n-1)
+	}
+	for i, slot := range *shards {
+		if slot.Load() == nil {
+			v.allocate(i, slot)
+		}
+	}
+}
+
+// allocate allocates the value of the empty slot of the given shard, unless
+// another goroutine did so first, and returns the value stored in the slot.
+func (v *Values[T]) allocate(shardID int, slot *atomic.Pointer[padded[T]]) *padded[T] {
+	p := &padded[T]{v: v.initial(shardID)}
+	if !slot.CompareAndSwap(nil, p) {
+		return slot.Load()
+	}
+	stats.shardsAllocated.Add(1)
+	return p
+}
+
+// initial returns the initial value of the given shard.
+func (v *Values[T]) initial(shardID int) T {
+	var x T
+	if v.init != nil {
+		x = v.init(shardID)
+	}
+	return x
+}
 
-	slot := (*shards)[shardID]
+// NumShards returns the number of shards v currently has, including the ones
+// whose value was not allocated yet. It is zero before the first Get.
+// The number can grow concurrently, but never shrinks.
+func (v *Values[T]) NumShards() int {
+	shards := v.shards.Load()
+	if shards == nil {
+		return 0
+	}
+	v.checkNotCopied()
+	return len(*shards)
+}
+
+// Shard returns a pointer to the value of the shard with index i, allocating
+// it if it was not used yet. Shard panics if i is not in the range
+// [0, NumShards()).
+//
+// Shard is meant for debugging and custom exporters. Other goroutines may be
+// using the value concurrently, so all access of the returned value must use
+// further synchronization mechanisms, like with Get.
+func (v *Values[T]) Shard(i int) *T {
+	shards := v.shards.Load()
+	n := 0
+	if shards != nil {
+		v.checkNotCopied()
+		n = len(*shards)
+	}
+	if i < 0 || i >= n {
+		panic(fmt.Sprintf("percpu: shard index %d out of range [0:%d]", i, n))
+	}
+	slot := (*shards)[i].Load()
+	if slot == nil {
+		slot = v.allocate(i, (*shards)[i])
+	}
 	return &slot.v
 }
 
@@ -86,28 +322,208 @@ func (v *Values[T]) Get() *T {
 //
 // fn may be called zero or more times.
 // fn might observe a new p before any goroutine calling Get has a chance to initialize it.
+// Shards whose value was never returned by Get are skipped, as they have not
+// been allocated yet.
 //
 // The pointers might be concurrently used by other goroutines.
 // The user is responsible for synchronizing access to p.
 func (v *Values[T]) Range(fn func(p *T)) {
+	v.rangeSlots(func(_ int, slot *padded[T]) bool {
+		if slot != nil {
+			fn(&slot.v)
+		}
+		return true
+	})
+}
+
+// RangeIndexed runs fn on all values in v together with the index of their
+// shard. Unlike Range, the order is stable: fn is called in increasing order
+// of the index, and a shard keeps its index forever, so the index can be used
+// to label the values (see also ShardLabel).
+//
+// RangeIndexed visits the same values as Range, with the same caveats.
+func (v *Values[T]) RangeIndexed(fn func(shard int, p *T)) {
+	v.rangeSlots(func(i int, slot *padded[T]) bool {
+		if slot != nil {
+			fn(i, &slot.v)
+		}
+		return true
+	})
+}
+
+// RangeWhile runs fn on the values in v like Range, until fn returns false.
+// This avoids visiting the remaining shards once the answer is known, for
+// example when looking for any shard in an error state.
+func (v *Values[T]) RangeWhile(fn func(p *T) bool) {
+	v.rangeSlots(func(_ int, slot *padded[T]) bool {
+		return slot == nil || fn(&slot.v)
+	})
+}
+
+// rangeSlots runs fn on the slot of every shard in v with the index of the
+// shard, until fn returns false. slot is nil if the value of the shard was
+// not allocated yet.
+func (v *Values[T]) rangeSlots(fn func(i int, slot *padded[T]) bool) {
 	shards := v.shards.Load()
 	if shards == nil {
 		return
 	}
+	v.checkNotCopied()
 
-	for _, shard := range *shards {
-		fn(&shard.v)
+	for i, shard := range *shards {
+		if !fn(i, shard.Load()) {
+			return
+		}
 	}
 }
 
-//go:linkname runtime_procPin runtime.procPin
-func runtime_procPin() int
+// rangeAllShards runs fn on all values in v with the index of their shard.
+// Unlike RangeIndexed, it does not skip unallocated shards: fn observes
+// a pointer to a fresh initial value for them instead, which must not be
+// retained.
+// It is meant for read-only views that report every shard, like Format.
+func (v *Values[T]) rangeAllShards(fn func(i int, p *T)) {
+	v.rangeSlots(func(i int, slot *padded[T]) bool {
+		if slot == nil {
+			initial := v.initial(i)
+			fn(i, &initial)
+		} else {
+			fn(i, &slot.v)
+		}
+		return true
+	})
+}
 
-//go:linkname runtime_procUnpin runtime.procUnpin
-func runtime_procUnpin() int
+// RangeSorted runs fn on all values in v, in the order defined by less
+// instead of the shard order. This is useful for reporting the top shards
+// first or for producing stable output.
+//
+// less and fn are subject to the same caveats as fn in Range. Values must not
+// change while they are sorted, or the order is unspecified.
+func (v *Values[T]) RangeSorted(less func(a, b *T) bool, fn func(p *T)) {
+	var ps []*T
+	v.Range(func(p *T) {
+		ps = append(ps, p)
+	})
+	sort.SliceStable(ps, func(i, j int) bool {
+		return less(ps[i], ps[j])
+	})
+	for _, p := range ps {
+		fn(p)
+	}
+}
 
-func getProcID() int {
-	pid := runtime_procPin()
-	runtime_procUnpin()
-	return pid
+// Clear resets all values in v by running fn on each of them. fn should reset
+// the value in place, for example by truncating a slice to zero length or
+// deleting the keys of a map, so that the capacity is reused in the next
+// interval instead of being allocated again.
+//
+// If fn is nil, Clear overwrites each value with its initial value: the zero
+// value, or the result of the init function passed to NewValuesFunc. As this
+// writes the values without synchronization, it must not run concurrently
+// with any other use of the values.
+//
+// Clear does not stop concurrent users of Get. fn must synchronize with them
+// like any other access to the values, and values updated by other goroutines
+// while Clear is running might be observed either before or after the reset.
+func (v *Values[T]) Clear(fn func(p *T)) {
+	if fn != nil {
+		v.Range(fn)
+		return
+	}
+	v.rangeSlots(func(i int, slot *padded[T]) bool {
+		if slot != nil {
+			slot.v = v.initial(i)
+		}
+		return true
+	})
+}
+
+// OnGrow sets a function that is called whenever v changes its set of
+// shards: on first use, after GOMAXPROCS increases and the new processors use
+// v, and after Compact releases shards, in which case newN is lower than oldN.
+// This allows exporters to register per-shard metrics for new shards without
+// polling NumShards.
+//
+// fn is called synchronously by the Get call that grew the shards, so it must
+// be fast. If the shards grew in the function passed to Pin, fn is called
+// from a new goroutine. A nil fn removes the callback.
+func (v *Values[T]) OnGrow(fn func(oldN, newN int)) {
+	if fn == nil {
+		v.onGrow.Store(nil)
+		return
+	}
+	v.onGrow.Store(&fn)
+}
+
+// Compact releases the shards that are no longer needed because GOMAXPROCS
+// decreased since v grew. The value of each released shard is first merged
+// into a remaining shard by calling merge with pointers to both values.
+//
+// Compact requires exclusive access to v: it must not run concurrently with
+// any other method of v, and pointers to values returned by v before must not
+// be used afterwards, as they might refer to a released shard.
+// If GOMAXPROCS increases again, v grows again.
+func (v *Values[T]) Compact(merge func(dst, src *T)) {
+	shards := v.shards.Load()
+	if shards == nil {
+		return
+	}
+	v.checkNotCopied()
+	n := v.opts.shardCount()
+	if len(*shards) <= n {
+		return
+	}
+	newShards := make([]*atomic.Pointer[padded[T]], n)
+	copy(newShards, *shards)
+	for i, cell := range (*shards)[n:] {
+		src := cell.Load()
+		if src == nil {
+			continue
+		}
+		dstID := i % n
+		dst := newShards[dstID].Load()
+		if dst == nil {
+			dst = v.allocate(dstID, newShards[dstID])
+		}
+		merge(&dst.v, &src.v)
+	}
+	v.shards.Store(&newShards)
+	notifyGrow(GrowEvent{OldShards: len(*shards), NewShards: n, ShardID: -1}, v.onGrow.Load(), v.opts.logger)
+}
+
+// Clone returns a new Values with the same configuration and shards as v.
+// copyFn is called on each shard of v to make the value of the corresponding
+// shard in the clone; it must synchronize with writers of the shard and
+// deep-copy any memory that must not be shared, like slices and maps.
+// Shards that were not allocated in v are not allocated in the clone either.
+func (v *Values[T]) Clone(copyFn func(p *T) T) *Values[T] {
+	c := &Values[T]{opts: v.opts, init: v.init}
+	n := v.NumShards()
+	if n == 0 {
+		return c
+	}
+	shards := make([]*atomic.Pointer[padded[T]], n)
+	slots := make([]atomic.Pointer[padded[T]], n)
+	for i := range shards {
+		shards[i] = &slots[i]
+	}
+	v.rangeSlots(func(i int, slot *padded[T]) bool {
+		if slot != nil && i < n {
+			slots[i].Store(&padded[T]{v: copyFn(&slot.v)})
+			stats.shardsAllocated.Add(1)
+		}
+		return true
+	})
+	c.self.Store(c)
+	c.shards.Store(&shards)
+	return c
+}
+
+// checkNotCopied panics if v is a copy of a Values that was in use.
+// Without the check, the copy would silently share shards with the original.
+func (v *Values[T]) checkNotCopied() {
+	if v.self.Load() != v {
+		panic("percpu: Values copied after first use")
+	}
 }
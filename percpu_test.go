@@ -1,17 +1,49 @@
 package percpu
 
 import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"reflect"
 	"runtime"
 	"sync"
 	"sync/atomic"
 	"testing"
+
+	"github.com/martin-sucha/percpu/internal/hooks"
 )
 
+// setProcIDs makes Get observe the given proc IDs in order until the test
+// finishes. If maxProcs is positive, it is used in place of GOMAXPROCS.
+func setProcIDs(t *testing.T, maxProcs int, ids ...int) {
+	t.Helper()
+	restore := hooks.Update(func(h *hooks.Hooks) {
+		h.ProcID = func() int {
+			if len(ids) == 0 {
+				t.Fatal("out of proc IDs")
+			}
+			id := ids[0]
+			ids = ids[1:]
+			return id
+		}
+		if maxProcs > 0 {
+			h.MaxProcs = func() int { return maxProcs }
+		}
+	})
+	t.Cleanup(restore)
+}
+
 func TestValues(t *testing.T) {
 	numProcs := runtime.GOMAXPROCS(0)
 	if numProcs > runtime.NumCPU() {
 		t.Skip("unreliable with high GOMAXPROCS")
 	}
+	if checkEnabled {
+		t.Skip("shards are random in checked mode")
+	}
+	if pureGo {
+		t.Skip("shards are hashed in pure Go mode")
+	}
 	// shard -> #goroutines for which the shard is the most frequently seen
 	freqCounts := make(map[*int]int)
 	// The short timing makes this a little flaky (a goroutine can just
@@ -62,13 +94,16 @@ attemptLoop:
 	t.Fatalf("shards were not handed out evenly to goroutines: %v", freqCounts)
 }
 
-// Confirm that getProcID runs and returns the full set of values
+// Confirm that procPinID runs and returns the full set of values
 // in [0, GOMAXPROCS) as a quick sanity check.
 func TestGetProcID(t *testing.T) {
 	numProcs := runtime.GOMAXPROCS(0)
 	if numProcs > runtime.NumCPU() {
 		t.Skip("unreliable with high GOMAXPROCS")
 	}
+	if pureGo {
+		t.Skip("processor IDs are not available in pure Go mode")
+	}
 	seen := make([]int64, numProcs)
 	start := make(chan struct{})
 	var wg sync.WaitGroup
@@ -78,7 +113,7 @@ func TestGetProcID(t *testing.T) {
 			defer wg.Done()
 			<-start
 			for i := 0; i < 1e6; i++ {
-				atomic.AddInt64(&seen[getProcID()], 1)
+				atomic.AddInt64(&seen[procPinID()], 1)
 			}
 		}()
 	}
@@ -91,6 +126,16 @@ func TestGetProcID(t *testing.T) {
 	}
 }
 
+func TestUpdate(t *testing.T) {
+	// The debug build looks up the shard twice per Update.
+	setProcIDs(t, 4, 2, 2)
+	var vs Values[int]
+	vs.Update(func(p *int) { *p += 5 })
+	if got := *vs.Shard(2); got != 5 {
+		t.Fatalf("got %d in shard 2; want 5", got)
+	}
+}
+
 func TestGrowShards(t *testing.T) {
 	numProcs := runtime.GOMAXPROCS(0)
 	if numProcs > runtime.NumCPU() {
@@ -99,6 +144,9 @@ func TestGrowShards(t *testing.T) {
 	if numProcs < 2 {
 		t.Skip("need initial GOMAXPROCS as least 2")
 	}
+	if checkEnabled {
+		t.Skip("shards are random in checked mode")
+	}
 
 	// shard -> #goroutines for which the shard is the most frequently seen
 	freqCounts := make(map[*int]int)
@@ -185,3 +233,277 @@ attemptLoop:
 
 	t.Fatalf("shards were not handed out evenly to goroutines: %v", freqCounts)
 }
+
+func TestCopyDetection(t *testing.T) {
+	var vs Values[int]
+	vs.Get()
+	vs.Range(func(*int) {})
+
+	// Copy via reflection, go vet would reject a plain assignment.
+	cp := new(Values[int])
+	reflect.ValueOf(cp).Elem().Set(reflect.ValueOf(&vs).Elem())
+	for name, fn := range map[string]func(){
+		"Get":   func() { cp.Get() },
+		"Range": func() { cp.Range(func(*int) {}) },
+	} {
+		func() {
+			defer func() {
+				if r := recover(); r != "percpu: Values copied after first use" {
+					t.Errorf("%s: got panic %v", name, r)
+				}
+			}()
+			fn()
+		}()
+	}
+}
+
+func TestRangeSorted(t *testing.T) {
+	setProcIDs(t, 4, 0, 1, 2, 3)
+	var vs Values[int]
+	for _, x := range []int{3, 1, 4, 1} {
+		*vs.Get() = x
+	}
+	var got []int
+	vs.RangeSorted(func(a, b *int) bool { return *a > *b }, func(p *int) {
+		got = append(got, *p)
+	})
+	want := []int{4, 3, 1, 1}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("got %v; want %v", got, want)
+		}
+	}
+}
+
+func TestClear(t *testing.T) {
+	setProcIDs(t, 2, 0, 1)
+	var v Values[[]int]
+	*v.Get() = append(make([]int, 0, 8), 1, 2)
+	*v.Get() = append(make([]int, 0, 8), 3)
+	v.Clear(func(p *[]int) {
+		*p = (*p)[:0]
+	})
+	v.Range(func(p *[]int) {
+		if len(*p) != 0 || cap(*p) != 8 {
+			t.Errorf("got len %d cap %d, want len 0 cap 8", len(*p), cap(*p))
+		}
+	})
+}
+
+func TestLazyAllocation(t *testing.T) {
+	setProcIDs(t, 4, 2, 2)
+	before := ReadStats()
+	var vs Values[int]
+	*vs.Get() = 7
+	if p := vs.Get(); *p != 7 {
+		t.Fatalf("got %d from the same shard; want 7", *p)
+	}
+	if d := ReadStats().ShardsAllocated - before.ShardsAllocated; d != 1 {
+		t.Fatalf("got %d shards allocated; want 1", d)
+	}
+	n := 0
+	vs.Range(func(*int) { n++ })
+	if n != 1 {
+		t.Fatalf("Range visited %d shards; want 1", n)
+	}
+	if got := fmt.Sprint(&vs); got != "[0 0 7 0]" {
+		t.Fatalf("got %q; want %q", got, "[0 0 7 0]")
+	}
+}
+
+func TestClearInitial(t *testing.T) {
+	setProcIDs(t, 2, 0, 1)
+	vs := NewValuesFunc(func(shard int) int { return 10 * shard })
+	*vs.Get() += 1
+	*vs.Get() += 2
+	vs.Clear(nil)
+	if got, want := fmt.Sprint(vs), "[0 10]"; got != want {
+		t.Fatalf("got %q; want %q", got, want)
+	}
+}
+
+func TestRangeIndexed(t *testing.T) {
+	setProcIDs(t, 4, 2, 0)
+	var vs Values[int]
+	*vs.Get() = 20
+	*vs.Get() = 0
+	var got []int
+	vs.RangeIndexed(func(shard int, p *int) {
+		if *p != 10*shard {
+			t.Errorf("got %d at shard %d; want %d", *p, shard, 10*shard)
+		}
+		got = append(got, shard)
+	})
+	if len(got) != 2 || got[0] != 0 || got[1] != 2 {
+		t.Fatalf("got shards %v; want [0 2]", got)
+	}
+}
+
+func TestRangeWhile(t *testing.T) {
+	setProcIDs(t, 3, 0, 1, 2)
+	var vs Values[int]
+	for _, x := range []int{1, -1, 2} {
+		*vs.Get() = x
+	}
+	var visited []int
+	vs.RangeWhile(func(p *int) bool {
+		visited = append(visited, *p)
+		return *p >= 0
+	})
+	if len(visited) != 2 || visited[1] != -1 {
+		t.Fatalf("visited %v; want to stop at -1", visited)
+	}
+}
+
+func TestNumShards(t *testing.T) {
+	setProcIDs(t, 3, 1, 5)
+	var vs Values[int]
+	if n := vs.NumShards(); n != 0 {
+		t.Fatalf("got %d shards before Get; want 0", n)
+	}
+	vs.Get()
+	if n := vs.NumShards(); n != 3 {
+		t.Fatalf("got %d shards; want 3", n)
+	}
+	vs.Get()
+	if n := vs.NumShards(); n != 6 {
+		t.Fatalf("got %d shards after growing; want 6", n)
+	}
+}
+
+func TestShard(t *testing.T) {
+	setProcIDs(t, 3, 1)
+	var vs Values[int]
+	*vs.Get() = 7
+	if p := vs.Shard(1); *p != 7 {
+		t.Fatalf("got %d from shard 1; want 7", *p)
+	}
+	*vs.Shard(2) = 9
+	n := 0
+	vs.Range(func(*int) { n++ })
+	if n != 2 {
+		t.Fatalf("Range visited %d shards; want 2 after Shard allocated one", n)
+	}
+	for _, i := range []int{-1, 3} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("Shard(%d) did not panic", i)
+				}
+			}()
+			vs.Shard(i)
+		}()
+	}
+}
+
+func TestCompact(t *testing.T) {
+	maxProcs, id := 4, 0
+	restore := hooks.Update(func(h *hooks.Hooks) {
+		h.ProcID = func() int { return id }
+		h.MaxProcs = func() int { return maxProcs }
+	})
+	defer restore()
+	var vs Values[int]
+	for id = 0; id < 4; id++ {
+		*vs.Get() = id + 1
+	}
+	maxProcs = 3
+	vs.Compact(func(dst, src *int) { *dst += *src })
+	if got, want := fmt.Sprint(&vs), "[5 2 3]"; got != want {
+		t.Fatalf("got %s after Compact; want %s", got, want)
+	}
+	vs.Compact(func(dst, src *int) { t.Fatal("merged without excess shards") })
+	maxProcs, id = 4, 3
+	*vs.Get() += 10
+	if got, want := fmt.Sprint(&vs), "[5 2 3 10]"; got != want {
+		t.Fatalf("got %s after growing again; want %s", got, want)
+	}
+}
+
+func TestClone(t *testing.T) {
+	setProcIDs(t, 3, 0, 2, 0)
+	vs := NewValuesFunc(func(int) []int { return []int{-1} }, WithShardLabels(func(i int) string { return "s" + fmt.Sprint(i) }))
+	*vs.Get() = []int{1}
+	*vs.Get() = []int{3}
+	c := vs.Clone(func(p *[]int) []int { return append([]int(nil), *p...) })
+	(*c.Get())[0] = 100
+	if got, want := fmt.Sprintf("%+v", vs), "[s0:[1] s1:[-1] s2:[3]]"; got != want {
+		t.Fatalf("original: got %s; want %s", got, want)
+	}
+	if got, want := fmt.Sprintf("%+v", c), "[s0:[100] s1:[-1] s2:[3]]"; got != want {
+		t.Fatalf("clone: got %s; want %s", got, want)
+	}
+}
+
+// Measure the fast path of Get, once the shard of the processor exists.
+func BenchmarkGet(b *testing.B) {
+	var vs Values[int64]
+	vs.Get()
+	b.Run("NonParallel", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			vs.Get()
+		}
+	})
+	b.Run("Parallel", func(b *testing.B) {
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				vs.Get()
+			}
+		})
+	})
+}
+
+// TestGetInlined checks that the compiler inlines Get into its callers, so
+// that the counters pay for a single call on their fast path.
+func TestGetInlined(t *testing.T) {
+	if testing.Short() {
+		t.Skip("runs the compiler")
+	}
+	goTool, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go command not found")
+	}
+	out, err := exec.Command(goTool, "build", "-gcflags=-m", ".").CombinedOutput()
+	if err != nil {
+		t.Fatalf("go build: %v\n%s", err, out)
+	}
+	if !bytes.Contains(out, []byte("can inline (*Values[int64]).Get")) {
+		t.Fatalf("Get is not inlinable; go build -gcflags=-m:\n%s", out)
+	}
+}
+
+func TestPreallocate(t *testing.T) {
+	setProcIDs(t, 3)
+	before := ReadStats()
+	var vs Values[int]
+	vs.Preallocate()
+	if n := vs.NumShards(); n != 3 {
+		t.Fatalf("got %d shards; want 3", n)
+	}
+	n := 0
+	vs.Range(func(*int) { n++ })
+	if n != 3 {
+		t.Fatalf("Range visited %d shards; want all 3 allocated", n)
+	}
+	vs.Preallocate()
+	after := ReadStats()
+	if d := after.ShardsAllocated - before.ShardsAllocated; d != 3 {
+		t.Fatalf("got %d shards allocated; want 3", d)
+	}
+	if d := after.Grows - before.Grows; d != 1 {
+		t.Fatalf("got %d grows; want 1", d)
+	}
+}
+
+func TestProcHint(t *testing.T) {
+	n := runtime.GOMAXPROCS(0)
+	for i := 0; i < 100; i++ {
+		if id := ProcHint(); id < 0 || id >= n {
+			t.Fatalf("got %d; want [0, %d)", id, n)
+		}
+	}
+	setProcIDs(t, 0, 7)
+	if id := ProcHint(); id != 7 {
+		t.Fatalf("got %d with a hooked proc ID; want 7", id)
+	}
+}
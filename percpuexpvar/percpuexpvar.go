@@ -0,0 +1,19 @@
+// Package percpuexpvar publishes the internal counters of the percpu package
+// as an expvar named "percpu".
+//
+// The package is imported only for its side effect:
+//
+//	import _ "github.com/martin-sucha/percpu/percpuexpvar"
+package percpuexpvar
+
+import (
+	"expvar"
+
+	"github.com/martin-sucha/percpu"
+)
+
+func init() {
+	expvar.Publish("percpu", expvar.Func(func() any {
+		return percpu.ReadStats()
+	}))
+}
@@ -0,0 +1,25 @@
+package percpuexpvar
+
+import (
+	"encoding/json"
+	"expvar"
+	"testing"
+
+	"github.com/martin-sucha/percpu"
+)
+
+func TestPublished(t *testing.T) {
+	var vs percpu.Values[int]
+	vs.Get()
+	v := expvar.Get("percpu")
+	if v == nil {
+		t.Fatal("percpu expvar is not published")
+	}
+	var got percpu.Stats
+	if err := json.Unmarshal([]byte(v.String()), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Grows == 0 || got.ShardsAllocated == 0 {
+		t.Fatalf("got %+v; want non-zero Grows and ShardsAllocated", got)
+	}
+}
@@ -0,0 +1,26 @@
+package percputest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/martin-sucha/percpu"
+)
+
+var _ percpu.Clock = (*FakeClock)(nil)
+
+func TestFakeClock(t *testing.T) {
+	start := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	c := NewFakeClock(start)
+	if got := c.Now(); !got.Equal(start) {
+		t.Fatalf("got %v; want %v", got, start)
+	}
+	c.Advance(time.Minute)
+	if got, want := c.Now(), start.Add(time.Minute); !got.Equal(want) {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+	c.Set(start)
+	if got := c.Now(); !got.Equal(start) {
+		t.Fatalf("got %v; want %v", got, start)
+	}
+}
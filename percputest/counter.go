@@ -0,0 +1,62 @@
+package percputest
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// A ResettableCounter is a counter that can be verified by CheckCounter.
+// percpu.Counter implements it.
+type ResettableCounter interface {
+	Add(n int64)
+	Load() int64
+	Reset() int64
+}
+
+// CheckCounter verifies that c does not lose or duplicate updates when Reset
+// runs concurrently to Add: goroutines run by Stress with cfg add to c while
+// another goroutine keeps resetting it. When all of them are done, the values
+// returned by Reset plus the value of c must add up to everything that was
+// added. CheckCounter fails the test otherwise.
+//
+// c must not be used by other goroutines while CheckCounter runs. It is left
+// reset when CheckCounter returns.
+func CheckCounter(tb testing.TB, c ResettableCounter, cfg StressConfig) {
+	tb.Helper()
+	initial := c.Load()
+	var added, seq atomic.Int64
+	var resets int64
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			resets += c.Reset()
+			c.Load()
+			runtime.Gosched()
+		}
+	}()
+	Stress(tb, cfg, StressOp{Name: "add", Fn: func() {
+		n := int64(splitmix64(uint64(seq.Add(1)))%16) - 4
+		c.Add(n)
+		added.Add(n)
+	}})
+	close(done)
+	wg.Wait()
+	rest := c.Reset()
+	if got, want := resets+rest, initial+added.Load(); got != want {
+		tb.Fatalf("percputest: counter total is %d (current %d + reset %d); added %d to initial %d",
+			got, rest, resets, added.Load(), initial)
+	}
+	if n := c.Load(); n != 0 {
+		tb.Fatalf("percputest: counter is %d after Reset with no concurrent Add", n)
+	}
+}
@@ -0,0 +1,36 @@
+package percputest
+
+import (
+	"testing"
+
+	"github.com/martin-sucha/percpu"
+)
+
+func TestCheckCounter(t *testing.T) {
+	c := percpu.NewCounter()
+	c.Add(3)
+	CheckCounter(t, c, StressConfig{Goroutines: 8, Ops: 500, MaxShards: 16})
+	if n := c.Load(); n != 0 {
+		t.Fatalf("got %d after CheckCounter; want 0", n)
+	}
+}
+
+// droppingCounter forgets the values it resets.
+type droppingCounter struct {
+	percpu.Counter
+}
+
+func (c *droppingCounter) Reset() int64 {
+	c.Counter.Reset()
+	return 0
+}
+
+func TestCheckCounterDetectsLoss(t *testing.T) {
+	r := &recordingTB{TB: t}
+	c := &droppingCounter{}
+	c.Add(1)
+	CheckCounter(r, c, StressConfig{Goroutines: 2, Ops: 10})
+	if !r.failed {
+		t.Fatal("CheckCounter did not detect lost updates")
+	}
+}
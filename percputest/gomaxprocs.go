@@ -0,0 +1,68 @@
+package percputest
+
+import (
+	"sync/atomic"
+	"testing"
+
+	"github.com/martin-sucha/percpu/internal/hooks"
+)
+
+// MaxProcs is a simulated GOMAXPROCS value installed by SetGOMAXPROCS.
+type MaxProcs struct {
+	n atomic.Int64
+}
+
+// SetGOMAXPROCS makes percpu size shards as if GOMAXPROCS was n until the
+// test finishes. The real GOMAXPROCS is not changed.
+//
+// The returned MaxProcs can be used to change the simulated value while
+// the test runs. Values grow the next time they see a proc ID that is out of
+// range of their current shards.
+func SetGOMAXPROCS(tb testing.TB, n int) *MaxProcs {
+	tb.Helper()
+	m := &MaxProcs{}
+	m.Set(n)
+	restore := hooks.Update(func(h *hooks.Hooks) {
+		h.MaxProcs = m.Get
+	})
+	tb.Cleanup(restore)
+	return m
+}
+
+// Set changes the simulated GOMAXPROCS value.
+// It panics if n <= 0.
+func (m *MaxProcs) Set(n int) {
+	if n <= 0 {
+		panic("percputest: non-positive GOMAXPROCS")
+	}
+	m.n.Store(int64(n))
+}
+
+// Get returns the simulated GOMAXPROCS value.
+func (m *MaxProcs) Get() int {
+	return int(m.n.Load())
+}
+
+// InterceptGrow calls fn every time a percpu.Values has prepared a grown set of
+// shards, right before it publishes it, until the test finishes.
+//
+// If fn grows the same Values (for example by calling Get while a Source
+// returns a higher proc ID), the publication fails and the interrupted Get has
+// to retry. This allows testing code that reacts to shard growth
+// deterministically.
+//
+// Growth that happens while fn runs does not call fn again.
+func InterceptGrow(tb testing.TB, fn func()) {
+	tb.Helper()
+	var running atomic.Bool
+	restore := hooks.Update(func(h *hooks.Hooks) {
+		h.BeforeGrow = func() {
+			if !running.CompareAndSwap(false, true) {
+				return
+			}
+			defer running.Store(false)
+			fn()
+		}
+	})
+	tb.Cleanup(restore)
+}
@@ -0,0 +1,65 @@
+package percputest
+
+import (
+	"testing"
+
+	"github.com/martin-sucha/percpu"
+)
+
+func countShards[T any](vs *percpu.Values[T]) int {
+	return vs.NumShards()
+}
+
+func TestSetGOMAXPROCS(t *testing.T) {
+	m := SetGOMAXPROCS(t, 4)
+	SetProcIDSource(t, Fixed(0))
+	var vs percpu.Values[int]
+	vs.Get()
+	if n := countShards(&vs); n != 4 {
+		t.Fatalf("got %d shards; want 4", n)
+	}
+	m.Set(8)
+	// No growth until a proc ID is out of range.
+	vs.Get()
+	if n := countShards(&vs); n != 4 {
+		t.Fatalf("got %d shards; want 4", n)
+	}
+	SetProcIDSource(t, Fixed(5))
+	vs.Get()
+	if n := countShards(&vs); n != 8 {
+		t.Fatalf("got %d shards; want 8", n)
+	}
+}
+
+func TestInterceptGrow(t *testing.T) {
+	SetGOMAXPROCS(t, 2)
+	var vs percpu.Values[int]
+	ids := []int{0}
+	SetProcIDSource(t, func() int { return ids[len(ids)-1] })
+	intercepted := 0
+	InterceptGrow(t, func() {
+		intercepted++
+		if intercepted == 1 {
+			// Grow concurrently to a higher shard count.
+			ids = append(ids, 6)
+			*vs.Get() = 42
+			ids = ids[:1]
+		}
+	})
+	p := vs.Get()
+	if n := countShards(&vs); n != 7 {
+		t.Fatalf("got %d shards; want 7", n)
+	}
+	if intercepted != 1 {
+		t.Fatalf("fn intercepted %d growths; want 1", intercepted)
+	}
+	var shards []*int
+	vs.Range(func(p *int) { shards = append(shards, p) })
+	if shards[0] != p {
+		t.Fatal("retried Get did not return the shard of the winning growth")
+	}
+	// Range skips the unused shards in between.
+	if len(shards) != 2 || *shards[1] != 42 {
+		t.Fatal("value stored during interception was lost")
+	}
+}
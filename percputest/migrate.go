@@ -0,0 +1,35 @@
+package percputest
+
+import (
+	"runtime"
+	"testing"
+
+	"github.com/martin-sucha/percpu/internal/hooks"
+)
+
+// AfterGet calls fn every time a percpu.Values.Get has resolved a shard,
+// right before Get returns, until the test finishes.
+//
+// This is an injection point between the shard resolution and the use of
+// the returned pointer, for example inside percpu.Counter.Add.
+func AfterGet(tb testing.TB, fn func()) {
+	tb.Helper()
+	restore := hooks.Update(func(h *hooks.Hooks) {
+		h.AfterGet = fn
+	})
+	tb.Cleanup(restore)
+}
+
+// ForceMigration simulates goroutines migrating to a different processor
+// between resolving a shard and using it, until the test finishes.
+//
+// After every Get, the calling goroutine yields so that other goroutines run
+// before it uses the returned pointer, and shards are selected at random as with
+// Chaos. This proves that code tolerates the documented caveat that
+// the pointer returned by Get may belong to another CPU and may be used
+// concurrently by other goroutines.
+func ForceMigration(tb testing.TB) {
+	tb.Helper()
+	Chaos(tb)
+	AfterGet(tb, runtime.Gosched)
+}
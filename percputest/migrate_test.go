@@ -0,0 +1,48 @@
+package percputest
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/martin-sucha/percpu"
+)
+
+func TestAfterGet(t *testing.T) {
+	SetProcIDSource(t, Fixed(0))
+	var vs percpu.Values[int]
+	var calls int
+	AfterGet(t, func() {
+		calls++
+		// The pointer was resolved, changing the source no longer affects it.
+		SetProcIDSource(t, Fixed(1))
+	})
+	p := vs.Get()
+	if calls != 1 {
+		t.Fatalf("got %d calls; want 1", calls)
+	}
+	var shards []*int
+	vs.Range(func(p *int) { shards = append(shards, p) })
+	if shards[0] != p {
+		t.Fatal("Get did not return shard 0")
+	}
+}
+
+func TestForceMigration(t *testing.T) {
+	SetGOMAXPROCS(t, 4)
+	ForceMigration(t)
+	c := percpu.NewCounter()
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				c.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := c.Load(); n != 800 {
+		t.Fatalf("got %d; want 800", n)
+	}
+}
@@ -0,0 +1,87 @@
+package percputest
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// ModelOp is an operation CheckModel applies to both a sharded type S and
+// a sequential model M of it.
+type ModelOp[S, M any] struct {
+	// Name describes the operation in failure messages.
+	Name string
+	// Run applies the operation to s and m, using r for random arguments.
+	// It returns an error if s and m disagree, for example if s returns
+	// a different result than m.
+	Run func(r *rand.Rand, s S, m M) error
+}
+
+// ModelConfig configures CheckModel. The zero value selects the defaults.
+type ModelConfig struct {
+	// Seed seeds the random choices. Zero means a seed based on the current
+	// time. The seed is included in failure messages, so a failure can be
+	// reproduced by setting it.
+	Seed int64
+	// Runs is the number of operation sequences to check. Defaults to 100.
+	Runs int
+	// Steps is the number of operations in each sequence. Defaults to 100.
+	Steps int
+	// Shards is the number of shards the operations are spread over.
+	// Each operation runs as if it was scheduled on a random processor.
+	// Defaults to 8.
+	Shards int
+}
+
+// CheckModel verifies that a sharded type behaves like its sequential model.
+//
+// For every run, CheckModel creates a fresh S using newS and a fresh M using
+// newM and applies a random sequence of ops to both. The operations run on
+// a single goroutine, but each one observes a random proc ID, so the state of
+// S ends up spread across shards.
+//
+// CheckModel replaces the proc ID source for the duration of the test,
+// see SetProcIDSource.
+func CheckModel[S, M any](tb testing.TB, cfg ModelConfig, newS func() S, newM func() M, ops ...ModelOp[S, M]) {
+	tb.Helper()
+	if len(ops) == 0 {
+		tb.Fatal("percputest: CheckModel without operations")
+	}
+	if cfg.Seed == 0 {
+		cfg.Seed = time.Now().UnixNano()
+	}
+	if cfg.Runs <= 0 {
+		cfg.Runs = 100
+	}
+	if cfg.Steps <= 0 {
+		cfg.Steps = 100
+	}
+	if cfg.Shards <= 0 {
+		cfg.Shards = 8
+	}
+
+	var procID atomic.Int64
+	SetProcIDSource(tb, func() int {
+		return int(procID.Load())
+	})
+
+	r := rand.New(rand.NewSource(cfg.Seed))
+	var history []string
+	for run := 0; run < cfg.Runs; run++ {
+		s, m := newS(), newM()
+		history = history[:0]
+		for step := 0; step < cfg.Steps; step++ {
+			op := ops[r.Intn(len(ops))]
+			shard := r.Intn(cfg.Shards)
+			procID.Store(int64(shard))
+			history = append(history, fmt.Sprintf("%s@%d", op.Name, shard))
+			if err := op.Run(r, s, m); err != nil {
+				tb.Fatalf("percputest: model mismatch (seed %d, run %d, step %d): %v\noperations: %s",
+					cfg.Seed, run, step, err, strings.Join(history, " "))
+			}
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package percputest
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/martin-sucha/percpu"
+)
+
+func newCounter() *percpu.Counter {
+	return percpu.NewCounter()
+}
+
+type int64Model struct {
+	n int64
+}
+
+func TestCheckModel(t *testing.T) {
+	CheckModel(t, ModelConfig{Runs: 20, Steps: 50}, newCounter,
+		func() *int64Model { return &int64Model{} },
+		ModelOp[*percpu.Counter, *int64Model]{
+			Name: "add",
+			Run: func(r *rand.Rand, c *percpu.Counter, m *int64Model) error {
+				n := r.Int63n(100) - 50
+				c.Add(n)
+				m.n += n
+				return nil
+			},
+		},
+		ModelOp[*percpu.Counter, *int64Model]{
+			Name: "load",
+			Run: func(r *rand.Rand, c *percpu.Counter, m *int64Model) error {
+				if got := c.Load(); got != m.n {
+					return fmt.Errorf("Load returned %d; want %d", got, m.n)
+				}
+				return nil
+			},
+		},
+		ModelOp[*percpu.Counter, *int64Model]{
+			Name: "reset",
+			Run: func(r *rand.Rand, c *percpu.Counter, m *int64Model) error {
+				got, want := c.Reset(), m.n
+				m.n = 0
+				if got != want {
+					return fmt.Errorf("Reset returned %d; want %d", got, want)
+				}
+				return nil
+			},
+		},
+	)
+}
+
+func TestCheckModelReportsMismatch(t *testing.T) {
+	r := &recordingTB{TB: t}
+	CheckModel(r, ModelConfig{Runs: 1, Steps: 10}, newCounter,
+		func() *int64Model { return &int64Model{} },
+		ModelOp[*percpu.Counter, *int64Model]{
+			Name: "broken",
+			Run: func(r *rand.Rand, c *percpu.Counter, m *int64Model) error {
+				return fmt.Errorf("always fails")
+			},
+		},
+	)
+	if !r.failed {
+		t.Fatal("CheckModel did not report the mismatch")
+	}
+}
@@ -0,0 +1,141 @@
+// Package percputest provides utilities for testing code that uses
+// the percpu package.
+//
+// By default, the shard a goroutine observes depends on the processor it is
+// scheduled on, so tests exercising per-shard behavior are not reproducible.
+// The functions in this package replace the shard selection of all
+// percpu.Values (and types built on top of them, like percpu.Counter)
+// in the process for the duration of a test.
+//
+// The hooks take precedence over the GODEBUG settings recognized by percpu.
+// They are process-wide, so tests that use them must not run in parallel
+// with other tests that use percpu.
+package percputest
+
+import (
+	"math/rand"
+	"runtime"
+	"sync/atomic"
+	"testing"
+
+	"github.com/martin-sucha/percpu/internal/hooks"
+)
+
+// A Source returns the proc ID percpu uses to select a shard in place of the
+// ID of the processor the calling goroutine runs on.
+//
+// A Source must be safe for concurrent use and must return non-negative IDs.
+// The ID does not need to be lower than GOMAXPROCS, Values grow to
+// accommodate any ID.
+type Source func() int
+
+// Fixed returns a Source that always returns id.
+func Fixed(id int) Source {
+	checkID(id)
+	return func() int {
+		return id
+	}
+}
+
+// RoundRobin returns a Source that cycles through IDs 0, 1, ..., n-1.
+// It panics if n <= 0.
+func RoundRobin(n int) Source {
+	if n <= 0 {
+		panic("percputest: RoundRobin with non-positive n")
+	}
+	var next atomic.Uint64
+	return func() int {
+		return int((next.Add(1) - 1) % uint64(n))
+	}
+}
+
+// Scripted returns a Source that returns ids in the given order.
+// After the last ID, it starts over from the first one.
+// It panics if ids is empty.
+func Scripted(ids ...int) Source {
+	if len(ids) == 0 {
+		panic("percputest: Scripted without ids")
+	}
+	for _, id := range ids {
+		checkID(id)
+	}
+	ids = append([]int(nil), ids...)
+	var next atomic.Uint64
+	return func() int {
+		return ids[(next.Add(1)-1)%uint64(len(ids))]
+	}
+}
+
+func checkID(id int) {
+	if id < 0 {
+		panic("percputest: negative proc ID")
+	}
+}
+
+// SetProcIDSource makes percpu select shards using src until the test
+// finishes.
+func SetProcIDSource(tb testing.TB, src Source) {
+	tb.Helper()
+	restore := hooks.Update(func(h *hooks.Hooks) {
+		h.ProcID = src
+	})
+	tb.Cleanup(restore)
+}
+
+// SetProcID makes every Get of every percpu.Values return the shard of
+// processor id until the test finishes. It is a shorthand for
+// SetProcIDSource(tb, Fixed(id)).
+func SetProcID(tb testing.TB, id int) {
+	tb.Helper()
+	SetProcIDSource(tb, Fixed(id))
+}
+
+// SingleShard makes every percpu.Values use exactly one shard until the test
+// finishes, even if it was created with percpu.WithShards, so code built on
+// percpu sees deterministic totals and ordering regardless of GOMAXPROCS.
+// As all goroutines access the same shard, the race detector reliably reports
+// unsynchronized access of the values.
+//
+// To disable sharding for a whole test binary instead, run it with
+// GODEBUG=percpushards=1.
+func SingleShard(tb testing.TB) {
+	tb.Helper()
+	SetGOMAXPROCS(tb, 1)
+	SetProcIDSource(tb, Fixed(0))
+	restore := hooks.Update(func(h *hooks.Hooks) {
+		h.Shards = 1
+	})
+	tb.Cleanup(restore)
+}
+
+// Random returns a Source that returns a uniformly random ID in [0, n) on
+// every call. It panics if n <= 0.
+func Random(n int) Source {
+	if n <= 0 {
+		panic("percputest: Random with non-positive n")
+	}
+	return func() int {
+		// The top-level functions of math/rand do not lock, so they don't
+		// introduce synchronization that would hide races from the race
+		// detector.
+		return rand.Intn(n)
+	}
+}
+
+// Chaos makes every Get of every percpu.Values return a random shard until
+// the test finishes.
+//
+// This maximizes the interleaving of goroutines on shards, exposing code that
+// incorrectly assumes a goroutine keeps getting the same shard, or that
+// accesses values without synchronization. Combine it with the race detector.
+//
+// The shards are chosen among GOMAXPROCS shards, or the simulated value if
+// SetGOMAXPROCS was called before.
+func Chaos(tb testing.TB) {
+	tb.Helper()
+	n := runtime.GOMAXPROCS(0)
+	if h := hooks.Load(); h != nil && h.MaxProcs != nil {
+		n = h.MaxProcs()
+	}
+	SetProcIDSource(tb, Random(n))
+}
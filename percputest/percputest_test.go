@@ -0,0 +1,121 @@
+package percputest
+
+import (
+	"sync/atomic"
+	"testing"
+
+	"github.com/martin-sucha/percpu"
+)
+
+func TestFixed(t *testing.T) {
+	SetProcIDSource(t, Fixed(3))
+	var vs percpu.Values[int]
+	p := vs.Get()
+	for i := 0; i < 100; i++ {
+		if got := vs.Get(); got != p {
+			t.Fatalf("Get returned a different shard on call %d", i)
+		}
+	}
+	if n := countShards(&vs); n < 4 {
+		t.Fatalf("got %d shards; want at least 4", n)
+	}
+}
+
+func TestRoundRobin(t *testing.T) {
+	SetProcIDSource(t, RoundRobin(4))
+	c := percpu.NewCounter()
+	for i := 0; i < 8; i++ {
+		c.Add(1)
+	}
+	var vs percpu.Values[atomic.Int64]
+	for i := 0; i < 8; i++ {
+		vs.Get().Add(int64(i))
+	}
+	var got []int64
+	vs.Range(func(p *atomic.Int64) {
+		got = append(got, p.Load())
+	})
+	want := []int64{0 + 4, 1 + 5, 2 + 6, 3 + 7}
+	for i, w := range want {
+		if got[i] != w {
+			t.Fatalf("got shard values %v; want %v", got, want)
+		}
+	}
+	if n := c.Load(); n != 8 {
+		t.Fatalf("got total %d; want 8", n)
+	}
+}
+
+func TestScripted(t *testing.T) {
+	src := Scripted(2, 0, 2)
+	want := []int{2, 0, 2, 2, 0, 2}
+	for i, w := range want {
+		if got := src(); got != w {
+			t.Fatalf("call %d: got %d; want %d", i, got, w)
+		}
+	}
+}
+
+func TestSetProcIDSourceRestores(t *testing.T) {
+	var vs percpu.Values[int]
+	SetProcIDSource(t, Fixed(1))
+	t.Run("inner", func(t *testing.T) {
+		SetProcIDSource(t, Fixed(0))
+		vs.Get()
+	})
+	if got, want := vs.Get(), vs.Get(); got != want {
+		t.Fatal("Get returned different shards")
+	}
+	var shards []*int
+	vs.Range(func(p *int) { shards = append(shards, p) })
+	if len(shards) < 2 || shards[1] != vs.Get() {
+		t.Fatal("outer source was not restored after subtest")
+	}
+}
+
+func TestSetProcID(t *testing.T) {
+	var vs percpu.Values[int]
+	SetProcID(t, 3)
+	*vs.Get() = 42
+	if got := *vs.Shard(3); got != 42 {
+		t.Fatalf("got %d in shard 3; want 42", got)
+	}
+}
+
+func TestSingleShard(t *testing.T) {
+	SingleShard(t)
+	var vs percpu.Values[int]
+	p := vs.Get()
+	if n := countShards(&vs); n != 1 {
+		t.Fatalf("got %d shards; want 1", n)
+	}
+	if vs.Get() != p {
+		t.Fatal("Get returned a different shard")
+	}
+}
+
+func TestSingleShardWithShards(t *testing.T) {
+	SingleShard(t)
+	vs := percpu.NewValues[int](percpu.WithShards(4))
+	vs.Get()
+	if n := countShards(vs); n != 1 {
+		t.Fatalf("got %d shards; want 1", n)
+	}
+	f := percpu.NewFlat[int](percpu.WithShards(4))
+	if n := f.NumShards(); n != 1 {
+		t.Fatalf("got %d Flat shards; want 1", n)
+	}
+}
+
+func TestChaos(t *testing.T) {
+	SetGOMAXPROCS(t, 4)
+	Chaos(t)
+	var vs percpu.Values[int]
+	seen := make(map[*int]bool)
+	for i := 0; i < 1000; i++ {
+		seen[vs.Get()] = true
+	}
+	if len(seen) != 4 {
+		t.Fatalf("Get returned %d distinct shards; want 4", len(seen))
+	}
+}
@@ -0,0 +1,63 @@
+package percputest
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/martin-sucha/percpu"
+)
+
+// CheckedCounter is a percpu.Counter shadowed by a mutex-protected reference
+// counter. Every update is applied to both, so the aggregation of the sharded
+// counter can be validated against the reference at checkpoints.
+//
+// A zero CheckedCounter is ready to use.
+type CheckedCounter struct {
+	c percpu.Counter
+
+	mu       sync.Mutex
+	total    int64
+	resetSum int64
+}
+
+// Add adds n to both counters.
+func (c *CheckedCounter) Add(n int64) {
+	c.c.Add(n)
+	c.mu.Lock()
+	c.total += n
+	c.mu.Unlock()
+}
+
+// Reset resets the sharded counter and reports the old value.
+// The reference counter is reset at the next checkpoint, because Reset does not
+// observe a consistent view of the shards when it is called concurrently to Add.
+func (c *CheckedCounter) Reset() int64 {
+	n := c.c.Reset()
+	c.mu.Lock()
+	c.resetSum += n
+	c.mu.Unlock()
+	return n
+}
+
+// Load returns the value of the sharded counter.
+func (c *CheckedCounter) Load() int64 {
+	return c.c.Load()
+}
+
+// Check fails the test if the sharded counter disagrees with the reference.
+//
+// Check must only be called at a checkpoint, when no Add or Reset is in
+// progress. Between checkpoints, the values returned by Reset plus the current
+// value must add up to everything that was added.
+func (c *CheckedCounter) Check(tb testing.TB) {
+	tb.Helper()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	got := c.c.Load() + c.resetSum
+	if got != c.total {
+		tb.Fatalf("percputest: sharded counter total is %d (current %d + reset %d); reference is %d",
+			got, got-c.resetSum, c.resetSum, c.total)
+	}
+	c.total -= c.resetSum
+	c.resetSum = 0
+}
@@ -0,0 +1,47 @@
+package percputest
+
+import (
+	"sync"
+	"testing"
+)
+
+type recordingTB struct {
+	testing.TB
+	failed bool
+}
+
+func (r *recordingTB) Fatalf(string, ...any) {
+	r.failed = true
+}
+
+func TestCheckedCounter(t *testing.T) {
+	SetProcIDSource(t, RoundRobin(4))
+	var c CheckedCounter
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				c.Add(2)
+				if j%25 == 0 {
+					c.Reset()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	c.Check(t)
+	c.Add(-5)
+	c.Check(t)
+	if n := c.Load(); n != c.total {
+		t.Fatalf("got %d; reference %d", n, c.total)
+	}
+
+	r := &recordingTB{TB: t}
+	c.c.Add(1) // bypass the reference
+	c.Check(r)
+	if !r.failed {
+		t.Fatal("Check did not detect a mismatch")
+	}
+}
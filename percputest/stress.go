@@ -0,0 +1,137 @@
+package percputest
+
+import (
+	"math/rand"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// StressOp is an operation run by Stress.
+type StressOp struct {
+	// Name describes the operation.
+	Name string
+	// Weight is the frequency of the operation relative to the other
+	// operations. Operations with zero Weight are run with weight 1.
+	Weight int
+	// Fn runs the operation. It is called concurrently from many goroutines.
+	Fn func()
+}
+
+// StressConfig configures Stress. The zero value selects the defaults.
+type StressConfig struct {
+	// Goroutines is the number of goroutines running the operations.
+	// Defaults to 4*GOMAXPROCS.
+	Goroutines int
+	// Ops is the number of operations run by each goroutine.
+	// Defaults to 1000.
+	Ops int
+	// Seed seeds the random choices. Zero means a seed based on the current
+	// time. The seed is logged when the test fails, so a failure can be
+	// reproduced by setting it.
+	Seed int64
+	// YieldProbability is the probability that a goroutine yields the
+	// processor before an operation. Defaults to 0.1; negative disables
+	// yielding.
+	YieldProbability float64
+	// MaxShards enables simulated shard growth: operations observe random
+	// proc IDs, and the range of the IDs grows up to MaxShards while the
+	// operations run. This forces every percpu.Values used by the operations
+	// to grow concurrently with the other operations.
+	MaxShards int
+}
+
+// Stress runs ops concurrently in a random mix to shake out races in types
+// built on top of percpu.Values. It is best run with the race detector.
+//
+// Stress returns when all operations have finished. Invariants of the tested
+// type can be verified after that.
+func Stress(tb testing.TB, cfg StressConfig, ops ...StressOp) {
+	tb.Helper()
+	if len(ops) == 0 {
+		tb.Fatal("percputest: Stress without operations")
+	}
+	if cfg.Goroutines <= 0 {
+		cfg.Goroutines = 4 * runtime.GOMAXPROCS(0)
+	}
+	if cfg.Ops <= 0 {
+		cfg.Ops = 1000
+	}
+	if cfg.Seed == 0 {
+		cfg.Seed = time.Now().UnixNano()
+	}
+	if cfg.YieldProbability == 0 {
+		cfg.YieldProbability = 0.1
+	}
+	seed := cfg.Seed
+	tb.Cleanup(func() {
+		if tb.Failed() {
+			tb.Logf("percputest: Stress seed %d", seed)
+		}
+	})
+
+	weights := make([]int, len(ops))
+	total := 0
+	for i, op := range ops {
+		w := op.Weight
+		if w <= 0 {
+			w = 1
+		}
+		total += w
+		weights[i] = total
+	}
+
+	var grow func(r *rand.Rand)
+	if cfg.MaxShards > 0 {
+		var limit atomic.Int64
+		limit.Store(1)
+		var state atomic.Uint64
+		state.Store(uint64(seed))
+		SetGOMAXPROCS(tb, 1)
+		SetProcIDSource(tb, func() int {
+			return int(splitmix64(state.Add(1)) % uint64(limit.Load()))
+		})
+		grow = func(r *rand.Rand) {
+			if n := limit.Load(); n < int64(cfg.MaxShards) && r.Intn(cfg.Ops) < cfg.MaxShards {
+				limit.CompareAndSwap(n, n+1)
+			}
+		}
+	}
+
+	var wg sync.WaitGroup
+	start := make(chan struct{})
+	for g := 0; g < cfg.Goroutines; g++ {
+		r := rand.New(rand.NewSource(seed + int64(g)))
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			for i := 0; i < cfg.Ops; i++ {
+				if r.Float64() < cfg.YieldProbability {
+					runtime.Gosched()
+				}
+				if grow != nil {
+					grow(r)
+				}
+				k := r.Intn(total)
+				j := 0
+				for weights[j] <= k {
+					j++
+				}
+				ops[j].Fn()
+			}
+		}()
+	}
+	close(start)
+	wg.Wait()
+}
+
+// splitmix64 is a cheap bijective mixing function.
+func splitmix64(x uint64) uint64 {
+	x += 0x9e3779b97f4a7c15
+	x = (x ^ (x >> 30)) * 0xbf58476d1ce4e5b9
+	x = (x ^ (x >> 27)) * 0x94d049bb133111eb
+	return x ^ (x >> 31)
+}
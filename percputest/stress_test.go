@@ -0,0 +1,33 @@
+package percputest
+
+import (
+	"sync/atomic"
+	"testing"
+
+	"github.com/martin-sucha/percpu"
+)
+
+func TestStress(t *testing.T) {
+	var c CheckedCounter
+	var vs percpu.Values[atomic.Int64]
+	Stress(t, StressConfig{Goroutines: 8, Ops: 500, MaxShards: 16},
+		StressOp{Name: "add", Weight: 10, Fn: func() { c.Add(1) }},
+		StressOp{Name: "reset", Fn: func() { c.Reset() }},
+		StressOp{Name: "load", Fn: func() { c.Load() }},
+		StressOp{Name: "get", Weight: 5, Fn: func() { vs.Get().Add(1) }},
+		StressOp{Name: "range", Fn: func() {
+			vs.Range(func(p *atomic.Int64) { p.Load() })
+		}},
+	)
+	c.Check(t)
+	var sum int64
+	n := 0
+	vs.Range(func(p *atomic.Int64) {
+		sum += p.Load()
+		n++
+	})
+	if n < 2 {
+		t.Fatalf("values did not grow: %d shards", n)
+	}
+	t.Logf("%d increments over %d shards", sum, n)
+}
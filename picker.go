@@ -0,0 +1,141 @@
+package percpu
+
+import (
+	"fmt"
+	"runtime"
+	"sync/atomic"
+	"unsafe"
+
+	"github.com/martin-sucha/percpu/internal/hooks"
+)
+
+// A Picker selects the shard that a goroutine uses.
+type Picker uint8
+
+const (
+	// PickProc selects the shard by the ID of the processor (a P, in the
+	// parlance of the Go runtime) running the goroutine.
+	PickProc Picker = iota
+	// PickHash selects the shard by hashing the address of the goroutine's
+	// stack. Goroutines have separate stacks, so they tend to use different
+	// shards, but the shard has no relation to the processor.
+	PickHash
+	// PickThread selects the shard by hashing the ID of the OS thread (an M,
+	// in the parlance of the Go runtime) running the goroutine. Unlike the
+	// processor ID, it spreads well over goroutines that spend most of their
+	// time in cgo calls or system calls, which keep many threads busy with
+	// few processors. On other systems than Linux, PickThread falls back to
+	// PickHash.
+	//
+	// PickThread is expensive: on Linux, every Get makes a gettid system
+	// call, because the thread ID cannot be cached while the runtime moves
+	// goroutines between threads. That costs around 150 ns per Get,
+	// compared to a few nanoseconds with PickProc (see
+	// BenchmarkThreadProcID). Only use it where the updates are rare
+	// compared to the calls that block the threads.
+	PickThread
+	// PickCPU selects the shard by the number of the CPU running the
+	// goroutine, as reported by the operating system. Unlike the processor
+	// ID, it corresponds to the hardware, which improves locality when
+	// threads are bound to CPUs, for example with taskset. CPU numbers can be
+	// higher than GOMAXPROCS, in which case the Values grows to have a shard
+	// for each of them. On Linux, the CPU number is read with a single RDPID
+	// or RDTSCP instruction on amd64 CPUs that support it, and with the
+	// getcpu system call otherwise. On other systems, PickCPU falls back to
+	// PickProc.
+	//
+	// Only PickCPU uses the instructions. The default PickProc reads the
+	// processor ID from the runtime, which costs about as much as RDPID
+	// and, unlike the CPU number, is always lower than GOMAXPROCS.
+	PickCPU
+)
+
+// pickers lists all valid Pickers.
+var pickers = []Picker{PickProc, PickHash, PickThread, PickCPU}
+
+// String returns the name of the Picker, as used in the percpupicker
+// GODEBUG setting.
+func (p Picker) String() string {
+	switch p {
+	case PickProc:
+		return "pin"
+	case PickHash:
+		return "hash"
+	case PickThread:
+		return "thread"
+	case PickCPU:
+		return "cpu"
+	default:
+		return fmt.Sprintf("Picker(%d)", uint8(p))
+	}
+}
+
+// pick returns a processor ID using p, unless the percpupicker GODEBUG
+// setting overrides it.
+func pick(p Picker) int {
+	if pickerForced {
+		p = forcedPicker
+	}
+	switch p {
+	case PickHash:
+		return hashProcID()
+	case PickThread:
+		return threadProcID()
+	case PickCPU:
+		return cpuProcID()
+	default:
+		return procPinID()
+	}
+}
+
+// ProcHint returns the ID of the processor (a P, in the parlance of the Go
+// runtime) that the calling goroutine is probably running on, for building
+// other sharded data structures. The ID is in the range [0, GOMAXPROCS) at the
+// time of the call. It is only a hint: the goroutine can migrate to another
+// processor at any time, even before ProcHint returns, and GOMAXPROCS can
+// change, so the result must not be used for anything but spreading load.
+//
+// In checked mode, ProcHint returns a random ID, and in pure Go mode, a hash
+// like PickHash.
+func ProcHint() int {
+	if h := hooks.Load(); h != nil && h.ProcID != nil {
+		return h.ProcID()
+	}
+	if checkEnabled {
+		return checkProcID(int(lastMaxProcs.Load()))
+	}
+	return procPinID()
+}
+
+func procPinID() int {
+	pid := procPin()
+	procUnpin()
+	return pid
+}
+
+// lastMaxProcs caches the last GOMAXPROCS value seen, for pickers that need
+// a shard count without calling runtime.GOMAXPROCS on every Get.
+var lastMaxProcs atomic.Int64
+
+func init() {
+	lastMaxProcs.Store(int64(runtime.GOMAXPROCS(0)))
+}
+
+func gomaxprocs() int {
+	n := runtime.GOMAXPROCS(0)
+	lastMaxProcs.Store(int64(n))
+	return n
+}
+
+// hashProcID returns an ID derived from the address of the calling
+// goroutine's stack.
+func hashProcID() int {
+	var x byte
+	return hashToken(uint64(uintptr(unsafe.Pointer(&x)) >> 11))
+}
+
+// hashToken maps token onto the processor IDs.
+func hashToken(token uint64) int {
+	token *= 0x9e3779b97f4a7c15
+	return int((token >> 32) % uint64(lastMaxProcs.Load()))
+}
@@ -0,0 +1,99 @@
+package percpu
+
+import (
+	"time"
+
+	"github.com/martin-sucha/percpu/internal/hooks"
+)
+
+// A Proc identifies the processor that a goroutine is pinned to by Pin.
+type Proc struct {
+	id int
+}
+
+// Pin runs fn with the calling goroutine pinned to the processor it is
+// running on. Updates done by fn through GetProc and AddProc land on the shards
+// of that processor, and the processor ID is looked up only once for all of
+// them. This is useful to update several values that belong to one logical
+// event, like a request count, a byte count and a latency sum.
+//
+// While pinned, the goroutine cannot be preempted and the processor cannot run
+// other goroutines, so fn must be short and must not block. In particular, it
+// must not do I/O, lock mutexes, send or receive on channels or call Pin.
+// A panic in fn crashes the program.
+// Building with the percpucheck build tag makes Pin panic if fn runs for too
+// long, and crashes the program if fn is still running after a much longer
+// time, which catches a fn that blocks.
+//
+// Pin always selects shards by the processor, regardless of the Picker of
+// the Values. In pure Go mode, Pin cannot pin the goroutine and selects shards
+// like PickHash instead.
+func Pin(fn func(p Proc)) {
+	var start time.Time
+	var watchdog *time.Timer
+	if checkEnabled {
+		start = time.Now()
+		watchdog = watchPinned()
+	}
+	id := procPin()
+	if h := hooks.Load(); h != nil && h.ProcID != nil {
+		id = h.ProcID()
+	} else if checkEnabled {
+		id = checkProcID(int(lastMaxProcs.Load()))
+	}
+	fn(Proc{id: id})
+	procUnpin()
+	if checkEnabled {
+		watchdog.Stop()
+		checkPinned(time.Since(start))
+	}
+}
+
+// GetProc is like Get, but returns the value of the shard of p.
+// It must only be called from the function passed to Pin.
+//
+// If v has to grow or allocate the value of the shard, which may block and
+// runs the init function of v, GetProc unpins the goroutine while doing so.
+// This only happens on the first use of the shard. The goroutine may then
+// continue on another processor, but the updates through p still land on
+// the shard of p.
+func (v *Values[T]) GetProc(p Proc) *T {
+	id := v.opts.mapProcID(p.id)
+	if slot := v.lookup(id); slot != nil {
+		return &slot.v
+	}
+	procUnpin()
+	slot := v.get(id)
+	procPin()
+	return &slot.v
+}
+
+// RunPinned runs fn on the value of the shard of the current processor while
+// the calling goroutine is pinned to it. Unlike with Get, the goroutine cannot
+// migrate to another processor before fn is done, so the update is local to
+// the processor. Other goroutines can still access the value through Range or
+// Shard, or through Get if they migrated before Get returned, so fn must use
+// the same synchronization as with Get.
+//
+// fn is subject to the same restrictions as the function passed to Pin.
+func (v *Values[T]) RunPinned(fn func(p *T)) {
+	Pin(func(p Proc) {
+		fn(v.GetProc(p))
+	})
+}
+
+// AddProc is like Add, but adds n to the shard of p.
+// It must only be called from the function passed to Pin.
+//
+// For a Counter created with WithConsistentReads or by a SnapshotGroup,
+// AddProc locks the shard like Add. The goroutine must not block while
+// pinned, so if a Load or Reset holds the locks, AddProc unpins the goroutine
+// until it can lock the shard, like GetProc does when allocating.
+func (c *Counter) AddProc(p Proc, n int64) {
+	if m := c.guard.lockProc(p); m != nil {
+		c.vs.GetProc(p).Add(n)
+		m.Unlock()
+		return
+	}
+	c.vs.GetProc(p).Add(n)
+}
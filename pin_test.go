@@ -0,0 +1,103 @@
+package percpu
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPin(t *testing.T) {
+	requests := NewCounter()
+	bytes := NewCounter()
+	var vs Values[int]
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				var p1, p2 *int
+				Pin(func(p Proc) {
+					requests.AddProc(p, 1)
+					bytes.AddProc(p, 10)
+					p1 = vs.GetProc(p)
+					p2 = vs.GetProc(p)
+				})
+				if p1 != p2 {
+					mu.Lock()
+					t.Errorf("GetProc returned different shards within one Pin")
+					mu.Unlock()
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if got := requests.Load(); got != 1000 {
+		t.Fatalf("got %d requests; want 1000", got)
+	}
+	if got := bytes.Load(); got != 10000 {
+		t.Fatalf("got %d bytes; want 10000", got)
+	}
+}
+
+func TestPinSameShard(t *testing.T) {
+	setProcIDs(t, 4, 2)
+	c := NewCounter()
+	Pin(func(p Proc) {
+		c.AddProc(p, 1)
+		c.AddProc(p, 2)
+	})
+	s := c.Snapshot()
+	if len(s.Shards) != 4 || s.Shards[2] != 3 {
+		t.Fatalf("got shards %v; want 3 in shard 2", s.Shards)
+	}
+}
+
+func TestPinGrowHookDoesNotRunPinned(t *testing.T) {
+	done := make(chan GrowEvent, 1)
+	SetGrowHook(func(e GrowEvent) {
+		// Blocking here would crash the runtime if it was called
+		// while pinned.
+		var mu sync.Mutex
+		mu.Lock()
+		mu.Unlock()
+		done <- e
+	})
+	defer SetGrowHook(nil)
+	var vs Values[int]
+	Pin(func(p Proc) {
+		vs.GetProc(p)
+	})
+	if e := <-done; e.OldShards != 0 || e.NewShards == 0 {
+		t.Fatalf("got event %+v", e)
+	}
+}
+
+func TestRunPinned(t *testing.T) {
+	setProcIDs(t, 4, 2, 2)
+	var vs Values[int]
+	vs.RunPinned(func(p *int) { *p += 5 })
+	if got := *vs.Shard(2); got != 5 {
+		t.Fatalf("got %d in shard 2; want 5", got)
+	}
+	if p := vs.Get(); *p != 5 {
+		t.Fatalf("Get returned a different shard than RunPinned")
+	}
+}
+
+func TestGetProcInitUnpinned(t *testing.T) {
+	// Sleeping would crash the runtime if init was called while pinned.
+	vs := NewValuesFunc(func(int) int {
+		time.Sleep(time.Millisecond)
+		return 1
+	})
+	var got int
+	Pin(func(p Proc) {
+		got = *vs.GetProc(p)
+	})
+	if got != 1 {
+		t.Fatalf("got %d; want the initial value 1", got)
+	}
+}
@@ -0,0 +1,21 @@
+//go:build !purego
+
+package percpu
+
+import (
+	_ "unsafe"
+)
+
+// pureGo reports whether the package is built with the purego build tag.
+const pureGo = false
+
+// procPin pins the calling goroutine to its processor and returns the ID of
+// the processor.
+//
+//go:linkname procPin runtime.procPin
+func procPin() int
+
+// procUnpin undoes procPin.
+//
+//go:linkname procUnpin runtime.procUnpin
+func procUnpin() int
@@ -0,0 +1,18 @@
+//go:build purego
+
+package percpu
+
+// pureGo reports whether the package is built with the purego build tag.
+const pureGo = true
+
+// procPin returns an ID derived from the stack of the calling goroutine, like
+// PickHash does, as the processor ID is not available without go:linkname.
+// It does not pin the goroutine.
+func procPin() int {
+	return hashProcID()
+}
+
+// procUnpin undoes procPin.
+func procUnpin() int {
+	return 0
+}
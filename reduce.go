@@ -0,0 +1,35 @@
+package percpu
+
+// Fold combines all values in v into an accumulator, starting with init.
+// fn is called for each value in the order used by Range and returns the new
+// accumulator.
+//
+// Like Range, Fold reads the shards without synchronization; fn is
+// responsible for synchronizing with concurrent writers.
+func Fold[T, R any](v *Values[T], init R, fn func(acc R, p *T) R) R {
+	acc := init
+	v.Range(func(p *T) {
+		acc = fn(acc, p)
+	})
+	return acc
+}
+
+// Reduce combines all values in v pairwise with fn, in the order used by
+// Range. It reports false if no shard of v was used.
+//
+// Like Range, Reduce reads the shards without synchronization, so it must not
+// run concurrently with code that modifies them, unless T synchronizes that
+// access itself.
+func Reduce[T any](v *Values[T], fn func(a, b T) T) (T, bool) {
+	var acc T
+	first := true
+	v.Range(func(p *T) {
+		if first {
+			acc = *p
+			first = false
+		} else {
+			acc = fn(acc, *p)
+		}
+	})
+	return acc, !first
+}
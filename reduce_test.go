@@ -0,0 +1,37 @@
+package percpu
+
+import (
+	"testing"
+)
+
+func TestFold(t *testing.T) {
+	setProcIDs(t, 3, 0, 1, 2)
+	var vs Values[[]string]
+	for _, s := range []string{"a", "bb", "ccc"} {
+		*vs.Get() = []string{s}
+	}
+	n := Fold(&vs, 0, func(n int, p *[]string) int { return n + len((*p)[0]) })
+	if n != 6 {
+		t.Fatalf("got %d; want 6", n)
+	}
+}
+
+func TestReduce(t *testing.T) {
+	var vs Values[int]
+	max := func(a, b int) int {
+		if a > b {
+			return a
+		}
+		return b
+	}
+	if _, ok := Reduce(&vs, max); ok {
+		t.Fatal("got ok for unused Values")
+	}
+	setProcIDs(t, 3, 0, 1, 2)
+	for _, x := range []int{4, 9, 2} {
+		*vs.Get() = x
+	}
+	if got, ok := Reduce(&vs, max); !ok || got != 9 {
+		t.Fatalf("got %d, %v; want 9, true", got, ok)
+	}
+}
@@ -0,0 +1,96 @@
+package percpu
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// A Registry holds named Counters, so that they can be exported together.
+//
+// A zero Registry is ready to use.
+type Registry struct {
+	mu       sync.RWMutex
+	counters map[string]*Counter
+	logger   atomic.Pointer[Logger]
+}
+
+// DefaultRegistry is the Registry used by NewRegisteredCounter and Handler.
+var DefaultRegistry = &Registry{}
+
+// NewCounter returns a fresh Counter registered as name.
+// It panics if name is already registered.
+func (r *Registry) NewCounter(name string) *Counter {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.counters[name]; ok {
+		panic("percpu: counter " + name + " is already registered")
+	}
+	if r.counters == nil {
+		r.counters = make(map[string]*Counter)
+	}
+	c := NewCounter(withLogger(&r.logger))
+	r.counters[name] = c
+	return c
+}
+
+// SetLogger sets the Logger that receives the internal events of the Counters
+// created by r, like shard growth, and the errors of ServeHTTP, in place of
+// the Logger set by the package-level SetLogger. A nil l makes them go to the
+// package-level Logger again, which is the default.
+func (r *Registry) SetLogger(l Logger) {
+	storeLogger(&r.logger, l)
+}
+
+// Counter returns the Counter registered as name, or nil if there is none.
+func (r *Registry) Counter(name string) *Counter {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.counters[name]
+}
+
+// Each calls fn for every registered Counter, in the order of their names.
+func (r *Registry) Each(fn func(name string, c *Counter)) {
+	r.mu.RLock()
+	names := make([]string, 0, len(r.counters))
+	for name := range r.counters {
+		names = append(names, name)
+	}
+	counters := make([]*Counter, len(names))
+	sort.Strings(names)
+	for i, name := range names {
+		counters[i] = r.counters[name]
+	}
+	r.mu.RUnlock()
+	for i, name := range names {
+		fn(name, counters[i])
+	}
+}
+
+// ServeHTTP writes the totals of all registered Counters as a JSON object
+// keyed by name.
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	totals := make(map[string]int64)
+	r.Each(func(name string, c *Counter) {
+		totals[name] = c.Load()
+	})
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	if err := json.NewEncoder(w).Encode(totals); err != nil {
+		logDebugTo(&r.logger, "percpu: writing registry failed", "err", err)
+	}
+}
+
+// NewRegisteredCounter returns a fresh Counter registered as name in
+// DefaultRegistry.
+// It panics if name is already registered.
+func NewRegisteredCounter(name string) *Counter {
+	return DefaultRegistry.NewCounter(name)
+}
+
+// Handler returns an http.Handler serving the totals of the Counters in
+// DefaultRegistry as a JSON object.
+func Handler() http.Handler {
+	return DefaultRegistry
+}
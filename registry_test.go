@@ -0,0 +1,54 @@
+package percpu
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRegistry(t *testing.T) {
+	var r Registry
+	hits := r.NewCounter("hits")
+	misses := r.NewCounter("misses")
+	hits.Add(3)
+	misses.Add(1)
+	if got := r.Counter("hits"); got != hits {
+		t.Fatal("Counter did not return the registered counter")
+	}
+	if got := r.Counter("bogus"); got != nil {
+		t.Fatal("Counter returned a counter for an unregistered name")
+	}
+	var names []string
+	r.Each(func(name string, c *Counter) { names = append(names, name) })
+	if strings.Join(names, ",") != "hits,misses" {
+		t.Fatalf("got names %v", names)
+	}
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	if got, want := rec.Body.String(), `{"hits":3,"misses":1}`+"\n"; got != want {
+		t.Fatalf("got body %q; want %q", got, want)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("registering a duplicate name did not panic")
+		}
+	}()
+	r.NewCounter("hits")
+}
+
+func TestDefaultRegistry(t *testing.T) {
+	c := DefaultRegistry.Counter("percpu_test_requests")
+	if c == nil {
+		// Not registered by a previous run with -count.
+		c = NewRegisteredCounter("percpu_test_requests")
+	}
+	c.Reset()
+	c.Add(2)
+	rec := httptest.NewRecorder()
+	Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	if !strings.Contains(rec.Body.String(), `"percpu_test_requests":2`) {
+		t.Fatalf("got body %q", rec.Body.String())
+	}
+}
@@ -0,0 +1,106 @@
+package percpu
+
+import (
+	"sync/atomic"
+)
+
+// A ResidencySampler samples which shard goroutines resolve to around hot
+// call sites. The resulting histogram shows whether goroutines are spread
+// across shards and whether they migrate between processors too often for
+// sharding to pay off.
+//
+// Wrap the hot section with Enter and Exit:
+//
+//	r := sampler.Enter()
+//	doWork()
+//	r.Exit()
+//
+// Only every n-th call of Enter on each shard is sampled, the others cost
+// an atomic increment on a CPU-local counter.
+type ResidencySampler struct {
+	every uint64
+	vs    Values[residencyShard]
+}
+
+type residencyShard struct {
+	ticks      atomic.Uint64
+	samples    atomic.Uint64
+	migrations atomic.Uint64
+}
+
+// NewResidencySampler returns a ResidencySampler that samples every n-th call
+// of Enter on each shard.
+// It panics if n <= 0.
+func NewResidencySampler(n int) *ResidencySampler {
+	if n <= 0 {
+		panic("percpu: NewResidencySampler with non-positive n")
+	}
+	return &ResidencySampler{every: uint64(n)}
+}
+
+// Residency is returned by ResidencySampler.Enter.
+type Residency struct {
+	s     *ResidencySampler
+	shard *residencyShard
+}
+
+// Enter marks the beginning of a sampled section.
+func (s *ResidencySampler) Enter() Residency {
+	shard := s.vs.Get()
+	if shard.ticks.Add(1)%s.every != 0 {
+		return Residency{}
+	}
+	shard.samples.Add(1)
+	return Residency{s: s, shard: shard}
+}
+
+// Exit marks the end of a section started by Enter. If the section was
+// sampled and the goroutine now resolves to a different shard than in Enter,
+// Exit records a migration.
+func (r Residency) Exit() {
+	if r.shard == nil {
+		return
+	}
+	if r.s.vs.Get() != r.shard {
+		r.shard.migrations.Add(1)
+	}
+}
+
+// ResidencyHistogram is a summary of samples taken by a ResidencySampler.
+type ResidencyHistogram struct {
+	// Samples holds the number of samples taken on each shard, by shard
+	// index.
+	Samples []uint64
+	// Migrations is the number of samples that ended on a different shard
+	// than they started.
+	Migrations uint64
+}
+
+// Total returns the total number of samples.
+func (h ResidencyHistogram) Total() uint64 {
+	var total uint64
+	for _, n := range h.Samples {
+		total += n
+	}
+	return total
+}
+
+// MigrationRate returns the fraction of samples that migrated,
+// or 0 if there are no samples.
+func (h ResidencyHistogram) MigrationRate() float64 {
+	total := h.Total()
+	if total == 0 {
+		return 0
+	}
+	return float64(h.Migrations) / float64(total)
+}
+
+// Histogram returns the samples taken so far.
+func (s *ResidencySampler) Histogram() ResidencyHistogram {
+	var h ResidencyHistogram
+	s.vs.rangeAllShards(func(_ int, shard *residencyShard) {
+		h.Samples = append(h.Samples, shard.samples.Load())
+		h.Migrations += shard.migrations.Load()
+	})
+	return h
+}
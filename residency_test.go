@@ -0,0 +1,35 @@
+package percpu
+
+import (
+	"testing"
+)
+
+func TestResidencySampler(t *testing.T) {
+	// Enter/Exit pairs: stay on 0, stay on 0, migrate 1->0, stay on 1.
+	setProcIDs(t, 2, 0, 0, 0, 0, 1, 0, 1, 1)
+	s := NewResidencySampler(1)
+	for i := 0; i < 4; i++ {
+		s.Enter().Exit()
+	}
+	h := s.Histogram()
+	if len(h.Samples) != 2 || h.Samples[0] != 2 || h.Samples[1] != 2 {
+		t.Fatalf("got samples %v; want [2 2]", h.Samples)
+	}
+	if h.Migrations != 1 {
+		t.Fatalf("got %d migrations; want 1", h.Migrations)
+	}
+	if r := h.MigrationRate(); r != 0.25 {
+		t.Fatalf("got migration rate %v; want 0.25", r)
+	}
+}
+
+func TestResidencySamplerEvery(t *testing.T) {
+	setProcIDs(t, 1, make([]int, 100)...)
+	s := NewResidencySampler(10)
+	for i := 0; i < 50; i++ {
+		s.Enter().Exit()
+	}
+	if total := s.Histogram().Total(); total != 5 {
+		t.Fatalf("got %d samples; want 5", total)
+	}
+}
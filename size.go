@@ -0,0 +1,78 @@
+package percpu
+
+import (
+	"fmt"
+	"reflect"
+	"sync/atomic"
+	"unsafe"
+)
+
+// CacheLineSize is the size of the padding Values puts around each shard,
+// which is the cache line size of the target architecture.
+//
+// Some CPUs prefetch pairs of 64-byte cache lines, and others, like Apple
+// Silicon, have 128-byte cache lines, so shards padded by 64 bytes can still
+// suffer from false sharing. Building with the percpupad128 build tag makes
+// the padding 128 bytes on all architectures.
+const CacheLineSize = unsafe.Sizeof(cacheLinePad{})
+
+// AssertFitsCacheLine panics if T is larger than CacheLineSize and otherwise
+// returns the size of T.
+//
+// It is meant to be used in package-level declarations, so that growing
+// a shard type beyond a cache line fails at init:
+//
+//	var _ = percpu.AssertFitsCacheLine[myShard]()
+func AssertFitsCacheLine[T any]() uintptr {
+	return AssertSizeAtMost[T](CacheLineSize)
+}
+
+// AssertSizeAtMost panics if T is larger than budget bytes and otherwise
+// returns the size of T. See AssertFitsCacheLine.
+func AssertSizeAtMost[T any](budget uintptr) uintptr {
+	var zero T
+	size := unsafe.Sizeof(zero)
+	if size > budget {
+		panic(fmt.Sprintf("percpu: %v is %d bytes, which exceeds the budget of %d bytes",
+			reflect.TypeOf((*T)(nil)).Elem(), size, budget))
+	}
+	return size
+}
+
+// SizeBytes reports the memory held by v itself: the Values struct, the slice
+// of slots, the slots of all shards and the padded values of the allocated
+// shards. It does not include memory referenced by the values, like the
+// contents of maps, nor memory of slices replaced by growth that was not
+// collected yet.
+func (v *Values[T]) SizeBytes() uintptr {
+	size := unsafe.Sizeof(*v)
+	if v.shards.Load() != nil {
+		size += unsafe.Sizeof([]*atomic.Pointer[padded[T]]{})
+	}
+	v.rangeSlots(func(_ int, slot *padded[T]) bool {
+		size += unsafe.Sizeof(slot) + unsafe.Sizeof(atomic.Pointer[padded[T]]{})
+		if slot != nil {
+			size += unsafe.Sizeof(*slot)
+		}
+		return true
+	})
+	return size
+}
+
+// paddedSlice returns a slice of n elements whose backing array has at least
+// CacheLineSize bytes of padding on each side, so that the elements do not
+// share cache lines with other allocations. It is used for shards holding
+// a variable number of values.
+func paddedSlice[T any](n int) []T {
+	pad := padLen(unsafe.Sizeof(*new(T)))
+	return make([]T, n+2*pad)[pad : pad+n : pad+n]
+}
+
+// padLen returns the number of elements of the given size that span at least
+// CacheLineSize bytes. Elements of size 0 need no padding.
+func padLen(size uintptr) int {
+	if size == 0 {
+		return 0
+	}
+	return int((CacheLineSize + size - 1) / size)
+}
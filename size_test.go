@@ -0,0 +1,96 @@
+package percpu
+
+import (
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"unsafe"
+)
+
+var _ = AssertFitsCacheLine[atomic.Int64]()
+
+func TestAssertSizeAtMost(t *testing.T) {
+	if got := AssertSizeAtMost[int32](4); got != 4 {
+		t.Fatalf("got size %d; want 4", got)
+	}
+	defer func() {
+		r := recover()
+		if r == nil {
+			t.Fatal("did not panic")
+		}
+		want := "percpu: [3]int32 is 12 bytes, which exceeds the budget of 8 bytes"
+		if got := fmt.Sprint(r); got != want {
+			t.Fatalf("got panic %q; want %q", got, want)
+		}
+	}()
+	AssertSizeAtMost[[3]int32](8)
+}
+
+func TestSizeBytes(t *testing.T) {
+	setProcIDs(t, 4, 1)
+	var vs Values[int64]
+	empty := vs.SizeBytes()
+	if empty != unsafe.Sizeof(vs) {
+		t.Fatalf("got %d bytes for unused Values; want %d", empty, unsafe.Sizeof(vs))
+	}
+	vs.Get()
+	ptr := unsafe.Sizeof(uintptr(0))
+	// The slice header of the slots, then each of the 4 shards has a slot
+	// pointer and a slot, one has a padded value.
+	want := empty + 3*ptr + 4*2*ptr + unsafe.Sizeof(padded[int64]{})
+	if got := vs.SizeBytes(); got != want {
+		t.Fatalf("got %d bytes; want %d", got, want)
+	}
+}
+
+func TestCacheLineSize(t *testing.T) {
+	var p padded[int64]
+	if off := unsafe.Offsetof(p.v); off != CacheLineSize {
+		t.Fatalf("value is at offset %d; want %d", off, CacheLineSize)
+	}
+	if CacheLineSize < 32 {
+		t.Fatalf("got CacheLineSize %d", CacheLineSize)
+	}
+}
+
+func TestAlign64(t *testing.T) {
+	setProcIDs(t, 3, 0, 1, 2, 0, 1, 2)
+	var vs Values[uint64]
+	f := NewFlat[uint64]()
+	for i := 0; i < 3; i++ {
+		for _, p := range []*uint64{vs.Get(), f.Get()} {
+			if addr := uintptr(unsafe.Pointer(p)); addr%8 != 0 {
+				t.Fatalf("value at %#x is not aligned to 64 bits", addr)
+			}
+			atomic.AddUint64(p, 1)
+		}
+	}
+}
+
+func TestPaddedSlice(t *testing.T) {
+	s := paddedSlice[int64](3)
+	// The capacity excludes the padding, so that append cannot use it.
+	if len(s) != 3 || cap(s) != 3 {
+		t.Fatalf("got len %d, cap %d; want 3, 3", len(s), cap(s))
+	}
+	if s := paddedSlice[[24]byte](3); len(s) != 3 || cap(s) != 3 {
+		t.Fatalf("got len %d, cap %d for [24]byte; want 3, 3", len(s), cap(s))
+	}
+	if s := paddedSlice[struct{}](3); len(s) != 3 || cap(s) != 3 {
+		t.Fatalf("got len %d, cap %d for struct{}; want 3, 3", len(s), cap(s))
+	}
+}
+
+func TestPadLen(t *testing.T) {
+	// 24 and 100 do not divide the cache line size, so the padding has to be
+	// rounded up to cover it.
+	for _, size := range []uintptr{1, 3, 8, 24, CacheLineSize, 100} {
+		n := uintptr(padLen(size))
+		if n*size < CacheLineSize || (n-1)*size >= CacheLineSize {
+			t.Errorf("got %d elements of %d bytes to pad %d bytes", n, size, CacheLineSize)
+		}
+	}
+	if n := padLen(0); n != 0 {
+		t.Errorf("got %d elements of 0 bytes; want 0", n)
+	}
+}
@@ -0,0 +1,102 @@
+package percpu
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Snapshot returns copies of the values of all shards, by shard index.
+// copyFn is called on each shard to take the copy; it must synchronize with
+// writers of the shard and must not return memory shared with the shard, for
+// example by cloning slices and maps. Shards that were not allocated yet are
+// reported with their initial value without calling copyFn.
+func (v *Values[T]) Snapshot(copyFn func(p *T) T) []T {
+	var s []T
+	v.rangeSlots(func(i int, slot *padded[T]) bool {
+		if slot == nil {
+			s = append(s, v.initial(i))
+		} else {
+			s = append(s, copyFn(&slot.v))
+		}
+		return true
+	})
+	return s
+}
+
+// CounterSnapshot holds the values of the shards of a Counter at one point
+// in time.
+type CounterSnapshot struct {
+	// Shards holds the value of each shard, by shard index. Shards that were
+	// not allocated yet are zero.
+	Shards []int64
+	// Labels holds the label of each shard, see WithShardLabels.
+	Labels []string
+	// Time is when the snapshot was taken, according to the Clock of the
+	// Counter (see WithClock).
+	Time time.Time
+}
+
+// Snapshot returns the current values of the shards of c.
+//
+// Like Load, Snapshot does not observe a consistent view of the shards if it
+// is called concurrently to Add or Reset, unless c was created with
+// WithConsistentReads.
+func (c *Counter) Snapshot() CounterSnapshot {
+	c.guard.lockAll()
+	defer c.guard.unlockAll()
+	s := CounterSnapshot{Time: c.vs.opts.now()}
+	c.vs.rangeAllShards(func(i int, v *atomic.Int64) {
+		s.Labels = append(s.Labels, c.vs.ShardLabel(i))
+		s.Shards = append(s.Shards, v.Load())
+	})
+	return s
+}
+
+// Total returns the sum of all shards.
+func (s CounterSnapshot) Total() int64 {
+	var sum int64
+	for _, n := range s.Shards {
+		sum += n
+	}
+	return sum
+}
+
+// Delta returns the per-shard increase from prev to s.
+//
+// Delta assumes the counter only grows between resets: a shard whose value is
+// lower than in prev is assumed to have been reset in the meantime, so its
+// delta is its current value. Shards that did not exist in prev start from
+// zero. The result has the Time of s.
+func (s CounterSnapshot) Delta(prev CounterSnapshot) CounterSnapshot {
+	d := CounterSnapshot{Shards: make([]int64, len(s.Shards)), Labels: s.Labels, Time: s.Time}
+	for i, n := range s.Shards {
+		var p int64
+		if i < len(prev.Shards) {
+			p = prev.Shards[i]
+		}
+		if n >= p {
+			d.Shards[i] = n - p
+		} else {
+			d.Shards[i] = n
+		}
+	}
+	return d
+}
+
+// Rate returns the total of s per second, given the elapsed time.
+// It is typically used on the result of Delta.
+// It returns 0 if elapsed is not positive.
+func (s CounterSnapshot) Rate(elapsed time.Duration) float64 {
+	if elapsed <= 0 {
+		return 0
+	}
+	return float64(s.Total()) / elapsed.Seconds()
+}
+
+// RateSince returns the per-second increase of the total from prev to s,
+// using the times at which the snapshots were taken. Resets are handled like
+// in Delta.
+// It returns 0 if s was not taken after prev.
+func (s CounterSnapshot) RateSince(prev CounterSnapshot) float64 {
+	return s.Delta(prev).Rate(s.Time.Sub(prev.Time))
+}
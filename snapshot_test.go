@@ -0,0 +1,97 @@
+package percpu
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestCounterSnapshotDelta(t *testing.T) {
+	prev := CounterSnapshot{Shards: []int64{10, 20}}
+	cur := CounterSnapshot{Shards: []int64{15, 5, 7}}
+	d := cur.Delta(prev)
+	want := []int64{5, 5, 7}
+	for i, w := range want {
+		if d.Shards[i] != w {
+			t.Fatalf("got delta %v; want %v", d.Shards, want)
+		}
+	}
+	if got := d.Total(); got != 17 {
+		t.Fatalf("got total %d; want 17", got)
+	}
+	if got := d.Rate(2 * time.Second); got != 8.5 {
+		t.Fatalf("got rate %v; want 8.5", got)
+	}
+	if got := d.Rate(0); got != 0 {
+		t.Fatalf("got rate %v for zero elapsed; want 0", got)
+	}
+}
+
+func TestCounterSnapshot(t *testing.T) {
+	setProcIDs(t, 2, 1, 0, 1)
+	c := NewCounter()
+	c.Add(3)
+	prev := c.Snapshot()
+	c.Add(4)
+	c.Add(5)
+	cur := c.Snapshot()
+	if got := cur.Total(); got != c.Load() {
+		t.Fatalf("got total %d; want %d", got, c.Load())
+	}
+	d := cur.Delta(prev)
+	if d.Shards[0] != 4 || d.Shards[1] != 5 {
+		t.Fatalf("got delta %v; want [4 5]", d.Shards)
+	}
+}
+
+func TestCounterSnapshotLabels(t *testing.T) {
+	setProcIDs(t, 2, 1)
+	workers := []string{"reader", "writer"}
+	c := NewCounter(WithShardLabels(func(shard int) string { return workers[shard] }))
+	c.Add(1)
+	s := c.Snapshot()
+	if len(s.Labels) != 2 || s.Labels[0] != "reader" || s.Labels[1] != "writer" {
+		t.Fatalf("got labels %v", s.Labels)
+	}
+	if d := s.Delta(CounterSnapshot{}); d.Labels[1] != "writer" {
+		t.Fatalf("got delta labels %v", d.Labels)
+	}
+	if got := c.Snapshot().Labels; NewCounter().vs.ShardLabel(3) != "3" || got[0] != "reader" {
+		t.Fatal("default label is not the shard index")
+	}
+}
+
+func TestCounterSnapshotRateSince(t *testing.T) {
+	setProcIDs(t, 2, 0, 1, 1)
+	c := NewCounter(WithClock(&stepClock{}))
+	c.Add(5)
+	prev := c.Snapshot()
+	c.Add(4)
+	c.Reset()
+	c.Add(3)
+	s := c.Snapshot()
+	if got, want := s.Time.Sub(prev.Time), time.Second; got != want {
+		t.Fatalf("got snapshots %v apart; want %v", got, want)
+	}
+	// Shard 0 was reset to 0 and shard 1 grew to 3.
+	if got := s.RateSince(prev); got != 3 {
+		t.Fatalf("got rate %v; want 3", got)
+	}
+	if got := prev.RateSince(s); got != 0 {
+		t.Fatalf("got rate %v for reversed snapshots; want 0", got)
+	}
+}
+
+func TestValuesSnapshot(t *testing.T) {
+	setProcIDs(t, 3, 2, 2, 2)
+	vs := NewValuesFunc(func(shard int) []int { return []int{shard} })
+	*vs.Get() = append(*vs.Get(), 5)
+	s := vs.Snapshot(func(p *[]int) []int { return append([]int(nil), *p...) })
+	s[2][0] = 100 // must not modify the shard
+	if got, want := fmt.Sprint(s), "[[0] [1] [100 5]]"; got != want {
+		t.Fatalf("got %s; want %s", got, want)
+	}
+	if got := (*vs.Get())[0]; got != 2 {
+		t.Fatalf("snapshot aliases shard memory: got %d; want 2", got)
+	}
+}
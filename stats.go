@@ -0,0 +1,41 @@
+package percpu
+
+import (
+	"sync/atomic"
+)
+
+// Stats counts internal events of the package across all Values in
+// the process. It makes the overhead of the package itself observable.
+//
+// Package percpuexpvar publishes Stats as an expvar.
+type Stats struct {
+	// Grows is the number of times a Values grew its set of shards.
+	Grows uint64
+	// GrowRetries is the number of times growing lost a race with
+	// a concurrent Get and had to be retried.
+	GrowRetries uint64
+	// ShardsAllocated is the total number of shard values allocated.
+	// Values are allocated when a shard is used for the first time.
+	ShardsAllocated uint64
+	// Migrations is the number of times a goroutine moved to another shard
+	// during Values.Update. It is only counted when the package is built
+	// with the percpudebug build tag.
+	Migrations uint64
+}
+
+var stats struct {
+	grows           atomic.Uint64
+	growRetries     atomic.Uint64
+	shardsAllocated atomic.Uint64
+	migrations      atomic.Uint64
+}
+
+// ReadStats returns the current values of the internal counters.
+func ReadStats() Stats {
+	return Stats{
+		Grows:           stats.grows.Load(),
+		GrowRetries:     stats.growRetries.Load(),
+		ShardsAllocated: stats.shardsAllocated.Load(),
+		Migrations:      stats.migrations.Load(),
+	}
+}
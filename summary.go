@@ -0,0 +1,132 @@
+package percpu
+
+import (
+	"math"
+	"sync"
+)
+
+// A Summary records the count, sum, minimum, maximum and sum of squares of
+// observed float64 values, like request latencies, and may be efficiently
+// updated by many goroutines concurrently. Load merges the shards into
+// a SummarySnapshot, which derives the mean and standard deviation.
+//
+// The variance is computed from the sum of squares, which loses precision if
+// the values are large compared to their spread. See Welford for a more
+// precise alternative.
+//
+// Like Counter, Load and Reset do not observe a consistent view of the shards
+// if they are called concurrently to Observe. The fields of one shard are
+// always consistent with each other.
+type Summary struct {
+	vs Values[summaryShard]
+}
+
+type summaryShard struct {
+	mu sync.Mutex
+	s  SummarySnapshot
+}
+
+// SummarySnapshot holds the aggregated observations of a Summary.
+type SummarySnapshot struct {
+	// Count is the number of observations.
+	Count int64
+	// Sum is the sum of the observed values.
+	Sum float64
+	// SumSquares is the sum of the squares of the observed values.
+	SumSquares float64
+	// Min and Max are the smallest and largest observed values.
+	// They are zero if there were no observations.
+	Min, Max float64
+}
+
+func (s *SummarySnapshot) observe(x float64) {
+	if s.Count == 0 || x < s.Min {
+		s.Min = x
+	}
+	if s.Count == 0 || x > s.Max {
+		s.Max = x
+	}
+	s.Count++
+	s.Sum += x
+	s.SumSquares += x * x
+}
+
+// merge adds the observations of o to s.
+func (s *SummarySnapshot) merge(o SummarySnapshot) {
+	if o.Count == 0 {
+		return
+	}
+	if s.Count == 0 || o.Min < s.Min {
+		s.Min = o.Min
+	}
+	if s.Count == 0 || o.Max > s.Max {
+		s.Max = o.Max
+	}
+	s.Count += o.Count
+	s.Sum += o.Sum
+	s.SumSquares += o.SumSquares
+}
+
+// Mean returns the mean of the observed values, or zero if there were none.
+func (s SummarySnapshot) Mean() float64 {
+	if s.Count == 0 {
+		return 0
+	}
+	return s.Sum / float64(s.Count)
+}
+
+// Variance returns the population variance of the observed values, or zero
+// if there were none.
+func (s SummarySnapshot) Variance() float64 {
+	if s.Count == 0 {
+		return 0
+	}
+	mean := s.Mean()
+	// Rounding can make the difference slightly negative.
+	return math.Max(0, s.SumSquares/float64(s.Count)-mean*mean)
+}
+
+// StdDev returns the population standard deviation of the observed values,
+// or zero if there were none.
+func (s SummarySnapshot) StdDev() float64 {
+	return math.Sqrt(s.Variance())
+}
+
+// NewSummary returns a Summary without observations.
+// The options configure the sharding of the Summary.
+func NewSummary(opts ...Option) *Summary {
+	s := &Summary{}
+	s.vs.opts.apply(opts)
+	return s
+}
+
+// Observe records x.
+func (s *Summary) Observe(x float64) {
+	sh := s.vs.Get()
+	sh.mu.Lock()
+	sh.s.observe(x)
+	sh.mu.Unlock()
+}
+
+// Load merges the observations of all shards.
+func (s *Summary) Load() SummarySnapshot {
+	return s.merge(false)
+}
+
+// Reset forgets all observations and reports what Load would return.
+func (s *Summary) Reset() SummarySnapshot {
+	return s.merge(true)
+}
+
+func (s *Summary) merge(reset bool) SummarySnapshot {
+	var total SummarySnapshot
+	s.vs.Range(func(sh *summaryShard) {
+		sh.mu.Lock()
+		total.merge(sh.s)
+		if reset {
+			sh.s = SummarySnapshot{}
+		}
+		sh.mu.Unlock()
+	})
+	return total
+}
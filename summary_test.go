@@ -0,0 +1,56 @@
+package percpu
+
+import (
+	"math"
+	"sync"
+	"testing"
+)
+
+func TestSummary(t *testing.T) {
+	setProcIDs(t, 3, 0, 1, 2, 0, 1, 2, 0, 1, 2)
+	s := NewSummary()
+	if got := s.Load(); got != (SummarySnapshot{}) || got.Mean() != 0 || got.StdDev() != 0 {
+		t.Fatalf("got %+v without observations", got)
+	}
+	for _, x := range []float64{2, 4, 4, 4, 5, 5, 7, 9} {
+		s.Observe(x)
+	}
+	got := s.Load()
+	want := SummarySnapshot{Count: 8, Sum: 40, SumSquares: 232, Min: 2, Max: 9}
+	if got != want {
+		t.Fatalf("got %+v; want %+v", got, want)
+	}
+	if got.Mean() != 5 || got.Variance() != 4 || got.StdDev() != 2 {
+		t.Fatalf("got mean %v, variance %v, stddev %v; want 5, 4, 2",
+			got.Mean(), got.Variance(), got.StdDev())
+	}
+	if got := s.Reset(); got != want {
+		t.Fatalf("Reset returned %+v; want %+v", got, want)
+	}
+	s.Observe(-1)
+	if got := s.Load(); got.Min != -1 || got.Max != -1 || got.Count != 1 {
+		t.Fatalf("got %+v after Reset", got)
+	}
+}
+
+func TestSummaryConcurrent(t *testing.T) {
+	s := NewSummary()
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 1; j <= 100; j++ {
+				s.Observe(float64(j))
+			}
+		}()
+	}
+	wg.Wait()
+	got := s.Load()
+	if got.Count != 1000 || got.Sum != 50500 || got.Min != 1 || got.Max != 100 {
+		t.Fatalf("got %+v", got)
+	}
+	if sd := got.StdDev(); math.Abs(sd-math.Sqrt(833.25)) > 1e-9 {
+		t.Fatalf("got stddev %v; want %v", sd, math.Sqrt(833.25))
+	}
+}
@@ -0,0 +1,250 @@
+package percpu
+
+import (
+	"math"
+	"sort"
+	"sync"
+)
+
+// A TDigest estimates quantiles of observed float64 values with a t-digest,
+// a sketch that is most precise at the extreme quantiles, like the 99th
+// percentile. It may be efficiently updated by many goroutines concurrently.
+//
+// The compression set when creating the TDigest bounds the number of
+// centroids that summarize the values, and with it the memory used and the
+// error of the estimates: with a compression of 100, a digest has at most
+// a few hundred centroids and estimates the median to within about 1% of
+// rank, and the extreme quantiles much more precisely.
+//
+// Each shard buffers observations and merges them into its own digest when
+// the buffer fills, so Observe mostly appends to a slice guarded by
+// a mutex of the shard. Load merges the digests of all shards into one.
+//
+// Like Counter, Load and Reset do not observe a consistent view of the shards
+// if they are called concurrently to Observe.
+//
+// A TDigest must be created with NewTDigest.
+type TDigest struct {
+	compression float64
+	vs          *Values[tdigestShard]
+}
+
+type tdigestShard struct {
+	mu     sync.Mutex
+	d      tdigest
+	buffer []centroid
+}
+
+type centroid struct {
+	mean, weight float64
+}
+
+// tdigest is a merged set of centroids, sorted by their means.
+type tdigest struct {
+	centroids []centroid
+	weight    float64
+	min, max  float64
+}
+
+// NewTDigest returns a TDigest without observations, using the given
+// compression. A compression of 100 is a good default.
+// The options configure the sharding of the TDigest.
+// It panics if compression is less than 10.
+func NewTDigest(compression float64, opts ...Option) *TDigest {
+	if !(compression >= 10) {
+		panic("percpu: NewTDigest with compression less than 10")
+	}
+	bufferSize := int(4 * compression)
+	return &TDigest{
+		compression: compression,
+		vs: NewValuesFunc(func(int) tdigestShard {
+			return tdigestShard{buffer: make([]centroid, 0, bufferSize)}
+		}, opts...),
+	}
+}
+
+// Observe records x. NaN is ignored.
+func (t *TDigest) Observe(x float64) {
+	t.ObserveWeighted(x, 1)
+}
+
+// ObserveWeighted records x with the given weight, as if x was observed
+// weight times, for example to record pre-aggregated values, or sampled values
+// with the inverse of their sampling probability. The weight does not need to
+// be an integer. NaN values and weights that are not positive and finite are
+// ignored.
+func (t *TDigest) ObserveWeighted(x, weight float64) {
+	if math.IsNaN(x) || !(weight > 0) || math.IsInf(weight, 0) {
+		return
+	}
+	s := t.vs.Get()
+	s.mu.Lock()
+	s.buffer = append(s.buffer, centroid{mean: x, weight: weight})
+	if len(s.buffer) == cap(s.buffer) {
+		s.d = s.d.merge(s.buffer, t.compression)
+		s.buffer = s.buffer[:0]
+	}
+	s.mu.Unlock()
+}
+
+// Load merges the observations of all shards.
+func (t *TDigest) Load() TDigestSnapshot {
+	return t.merge(false)
+}
+
+// Reset forgets all observations and reports what Load would return.
+func (t *TDigest) Reset() TDigestSnapshot {
+	return t.merge(true)
+}
+
+func (t *TDigest) merge(reset bool) TDigestSnapshot {
+	var all []centroid
+	// The means of the extreme centroids of the shards are not their
+	// extreme values, so those are tracked separately.
+	var min, max float64
+	extreme := func(x, y float64) {
+		if len(all) == 0 || x < min {
+			min = x
+		}
+		if len(all) == 0 || y > max {
+			max = y
+		}
+	}
+	t.vs.Range(func(s *tdigestShard) {
+		s.mu.Lock()
+		if len(s.d.centroids) > 0 {
+			extreme(s.d.min, s.d.max)
+			all = append(all, s.d.centroids...)
+		}
+		for _, c := range s.buffer {
+			extreme(c.mean, c.mean)
+			all = append(all, c)
+		}
+		if reset {
+			s.d = tdigest{}
+			s.buffer = s.buffer[:0]
+		}
+		s.mu.Unlock()
+	})
+	d := tdigest{}.merge(all, t.compression)
+	d.min, d.max = min, max
+	return TDigestSnapshot{d: d}
+}
+
+// merge returns a digest of the centroids of d and of cs, which may be
+// modified. It uses the k1 scale function, which limits the size of
+// centroids near the extreme quantiles.
+func (d tdigest) merge(cs []centroid, compression float64) tdigest {
+	cs = append(cs, d.centroids...)
+	if len(cs) == 0 {
+		return d
+	}
+	sort.Slice(cs, func(i, j int) bool { return cs[i].mean < cs[j].mean })
+	var total float64
+	for _, c := range cs {
+		total += c.weight
+	}
+	// The centroids of the result are new, so that d.centroids, which may be
+	// shared with the caller, is not modified.
+	out := tdigest{
+		centroids: make([]centroid, 0, int(compression)),
+		weight:    total,
+		min:       cs[0].mean,
+		max:       cs[len(cs)-1].mean,
+	}
+	if len(d.centroids) > 0 {
+		out.min = math.Min(out.min, d.min)
+		out.max = math.Max(out.max, d.max)
+	}
+	k := func(q float64) float64 { return compression / (2 * math.Pi) * math.Asin(2*q-1) }
+	kInv := func(k float64) float64 { return (math.Sin(k*2*math.Pi/compression) + 1) / 2 }
+	limit := func(q float64) float64 { return kInv(math.Min(k(q)+1, compression/4)) }
+
+	cur := cs[0]
+	var before float64
+	qLimit := limit(0)
+	for _, c := range cs[1:] {
+		if (before+cur.weight+c.weight)/total <= qLimit {
+			cur.weight += c.weight
+			cur.mean += (c.mean - cur.mean) * c.weight / cur.weight
+			continue
+		}
+		out.centroids = append(out.centroids, cur)
+		before += cur.weight
+		qLimit = limit(before / total)
+		cur = c
+	}
+	out.centroids = append(out.centroids, cur)
+	return out
+}
+
+// A TDigestSnapshot holds the merged digest of a TDigest.
+type TDigestSnapshot struct {
+	d tdigest
+}
+
+// Count returns the number of observations, which is the total weight rounded
+// down if ObserveWeighted was used. See Weight.
+func (s TDigestSnapshot) Count() uint64 {
+	return uint64(s.d.weight)
+}
+
+// Weight returns the total weight of the observations.
+func (s TDigestSnapshot) Weight() float64 {
+	return s.d.weight
+}
+
+// Min returns the smallest observed value, or zero if there were none.
+func (s TDigestSnapshot) Min() float64 {
+	return s.d.min
+}
+
+// Max returns the largest observed value, or zero if there were none.
+func (s TDigestSnapshot) Max() float64 {
+	return s.d.max
+}
+
+// Quantile returns an estimate of the value below which the fraction q of the
+// observed values lie, for example the 99th percentile for q = 0.99. q is
+// clamped to the range from 0 to 1, which return the exact minimum and
+// maximum.
+//
+// Each centroid stands for values spread around its mean, so the estimate
+// interpolates linearly between the means of the two centroids whose centers
+// of mass surround the rank q*Weight, and between the extreme centroids and
+// the minimum or maximum.
+// It returns 0 if there were no observations.
+func (s TDigestSnapshot) Quantile(q float64) float64 {
+	cs := s.d.centroids
+	if len(cs) == 0 {
+		return 0
+	}
+	q = math.Max(0, math.Min(1, q))
+	rank := q * s.d.weight
+	// center is the rank of the center of mass of the current centroid.
+	center := cs[0].weight / 2
+	if rank < center {
+		return s.d.min + (cs[0].mean-s.d.min)*rank/center
+	}
+	for i := 0; i < len(cs)-1; i++ {
+		next := center + (cs[i].weight+cs[i+1].weight)/2
+		if rank < next {
+			return cs[i].mean + (cs[i+1].mean-cs[i].mean)*(rank-center)/(next-center)
+		}
+		center = next
+	}
+	last := cs[len(cs)-1]
+	if rest := s.d.weight - center; rest > 0 {
+		return last.mean + (s.d.max-last.mean)*(rank-center)/rest
+	}
+	return s.d.max
+}
+
+// Quantiles returns the Quantile of each of qs.
+func (s TDigestSnapshot) Quantiles(qs ...float64) []float64 {
+	values := make([]float64, len(qs))
+	for i, q := range qs {
+		values[i] = s.Quantile(q)
+	}
+	return values
+}
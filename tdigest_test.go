@@ -0,0 +1,124 @@
+package percpu
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"sync"
+	"testing"
+)
+
+// rankError returns how far the rank of v in sorted is from q, as a fraction
+// of the number of values.
+func rankError(sorted []float64, v, q float64) float64 {
+	i := sort.SearchFloat64s(sorted, v)
+	return math.Abs(float64(i)/float64(len(sorted)) - q)
+}
+
+func TestTDigest(t *testing.T) {
+	d := NewTDigest(100)
+	r := rand.New(rand.NewSource(1))
+	values := make([]float64, 100000)
+	for i := range values {
+		values[i] = r.ExpFloat64()
+	}
+	var wg sync.WaitGroup
+	for g := 0; g < 10; g++ {
+		wg.Add(1)
+		go func(part []float64) {
+			defer wg.Done()
+			for _, v := range part {
+				d.Observe(v)
+			}
+		}(values[g*10000 : (g+1)*10000])
+	}
+	wg.Wait()
+	sort.Float64s(values)
+
+	s := d.Load()
+	if s.Count() != uint64(len(values)) {
+		t.Fatalf("got count %d; want %d", s.Count(), len(values))
+	}
+	if n := len(s.d.centroids); n > 300 {
+		t.Errorf("got %d centroids", n)
+	}
+	if s.Min() != values[0] || s.Max() != values[len(values)-1] {
+		t.Errorf("got min %v, max %v; want %v, %v", s.Min(), s.Max(), values[0], values[len(values)-1])
+	}
+	for _, tt := range []struct {
+		q, maxErr float64
+	}{
+		{0.01, 0.001},
+		{0.1, 0.005},
+		{0.5, 0.01},
+		{0.9, 0.005},
+		{0.99, 0.001},
+		{0.999, 0.0005},
+	} {
+		if e := rankError(values, s.Quantile(tt.q), tt.q); e > tt.maxErr {
+			t.Errorf("Quantile(%v) = %v is off by %v in rank", tt.q, s.Quantile(tt.q), e)
+		}
+	}
+	if qs := s.Quantiles(0, 1); qs[0] != s.Min() || qs[1] != s.Max() {
+		t.Errorf("got extreme quantiles %v; want min and max", qs)
+	}
+
+	if r := d.Reset(); r.Count() != s.Count() {
+		t.Fatalf("Reset returned count %d; want %d", r.Count(), s.Count())
+	}
+	if s := d.Load(); s.Count() != 0 || s.Quantile(0.5) != 0 {
+		t.Fatalf("got count %d after Reset", s.Count())
+	}
+}
+
+func TestTDigestFew(t *testing.T) {
+	setProcIDs(t, 2, 0, 1, 0)
+	d := NewTDigest(100)
+	d.Observe(1)
+	d.Observe(3)
+	d.Observe(math.NaN())
+	d.Observe(2)
+	s := d.Load()
+	if s.Count() != 3 {
+		t.Fatalf("got count %d; want 3", s.Count())
+	}
+	for q, want := range map[float64]float64{0: 1, 0.5: 2, 1: 3} {
+		if got := s.Quantile(q); got != want {
+			t.Errorf("Quantile(%v) = %v; want %v", q, got, want)
+		}
+	}
+}
+
+func TestTDigestObserveWeighted(t *testing.T) {
+	d := NewTDigest(100)
+	// The values below 0.5 weigh twice as much, so the median of the
+	// weighted values is 0.375 instead of 0.5.
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := g; i < 10000; i += 4 {
+				x := float64(i) / 10000
+				if x < 0.5 {
+					d.ObserveWeighted(x, 2)
+				} else {
+					d.ObserveWeighted(x, 0.5)
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+	d.ObserveWeighted(1, 0)
+	d.ObserveWeighted(1, math.Inf(1))
+	d.ObserveWeighted(math.NaN(), 1)
+	s := d.Load()
+	if s.Weight() != 12500 || s.Count() != 12500 {
+		t.Fatalf("got weight %v, count %d; want 12500", s.Weight(), s.Count())
+	}
+	for q, want := range map[float64]float64{0.2: 0.125, 0.5: 0.3125, 0.9: 0.75} {
+		if got := s.Quantile(q); math.Abs(got-want) > 0.005 {
+			t.Errorf("Quantile(%v) = %v; want %v", q, got, want)
+		}
+	}
+}
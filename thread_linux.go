@@ -0,0 +1,12 @@
+package percpu
+
+import (
+	"syscall"
+)
+
+// threadProcID returns an ID derived from the ID of the calling OS thread.
+// It makes a system call every time: the goroutine can move to another
+// thread between any two calls, so there is nothing to cache the ID in.
+func threadProcID() int {
+	return hashToken(uint64(syscall.Gettid()))
+}
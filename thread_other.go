@@ -0,0 +1,9 @@
+//go:build !linux
+
+package percpu
+
+// threadProcID falls back to hashProcID, as there is no portable way to get
+// the ID of the calling OS thread.
+func threadProcID() int {
+	return hashProcID()
+}
@@ -0,0 +1,41 @@
+package percpu
+
+import (
+	"runtime"
+	"sync"
+	"testing"
+)
+
+func TestThreadProcID(t *testing.T) {
+	n := int(lastMaxProcs.Load())
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			runtime.LockOSThread()
+			defer runtime.UnlockOSThread()
+			id := threadProcID()
+			if id < 0 || id >= n {
+				t.Errorf("got ID %d; want [0, %d)", id, n)
+			}
+			if again := threadProcID(); again != id {
+				t.Errorf("got ID %d, then %d on the same thread", id, again)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func BenchmarkThreadProcID(b *testing.B) {
+	b.Run("Thread", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			threadProcID()
+		}
+	})
+	b.Run("Proc", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			procPinID()
+		}
+	})
+}
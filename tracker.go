@@ -0,0 +1,141 @@
+package percpu
+
+import (
+	"math"
+	"strconv"
+	"sync/atomic"
+)
+
+// A MaxTracker records the largest int64 value observed, like the maximum
+// latency or queue depth in a reporting interval. It may be efficiently
+// updated by many goroutines concurrently.
+//
+// Each shard keeps the largest value observed on it. Observe only writes to
+// the shard if the value is larger than that, so once the maximum settles,
+// most calls are a plain load. Load merges the shards.
+//
+// Like Counter, Load and Reset do not observe a consistent view of the shards
+// if they are called concurrently to Observe. Reset does not lose
+// observations: each one is either reported by Reset or kept.
+//
+// A MaxTracker must be created with NewMaxTracker.
+type MaxTracker struct {
+	t tracker
+}
+
+// A MinTracker records the smallest int64 value observed. It is the
+// counterpart of MaxTracker, see there.
+//
+// A MinTracker must be created with NewMinTracker.
+type MinTracker struct {
+	t tracker
+}
+
+// tracker keeps the best value of each shard. The shards start at empty,
+// which is the worst possible value.
+type tracker struct {
+	empty int64
+	// better reports whether a should replace b.
+	better func(a, b int64) bool
+	// vs holds int64s accessed atomically, because shards initialized by
+	// NewValuesFunc cannot be of type atomic.Int64.
+	vs *Values[int64]
+}
+
+func newTracker(empty int64, better func(a, b int64) bool, opts []Option) tracker {
+	return tracker{
+		empty:  empty,
+		better: better,
+		vs:     NewValuesFunc(func(int) int64 { return empty }, opts...),
+	}
+}
+
+func (t *tracker) observe(v int64) {
+	s := t.vs.Get()
+	for {
+		old := atomic.LoadInt64(s)
+		if !t.better(v, old) || atomic.CompareAndSwapInt64(s, old, v) {
+			return
+		}
+	}
+}
+
+func (t *tracker) String() string {
+	v, ok := t.merge(false)
+	if !ok {
+		return "null"
+	}
+	return strconv.FormatInt(v, 10)
+}
+
+func (t *tracker) merge(reset bool) (int64, bool) {
+	best := t.empty
+	t.vs.Range(func(s *int64) {
+		var v int64
+		if reset {
+			v = atomic.SwapInt64(s, t.empty)
+		} else {
+			v = atomic.LoadInt64(s)
+		}
+		if t.better(v, best) {
+			best = v
+		}
+	})
+	return best, best != t.empty
+}
+
+// NewMaxTracker returns a MaxTracker without observations.
+// The options configure the sharding of the MaxTracker.
+func NewMaxTracker(opts ...Option) *MaxTracker {
+	return &MaxTracker{t: newTracker(math.MinInt64, func(a, b int64) bool { return a > b }, opts)}
+}
+
+// Observe records v.
+func (m *MaxTracker) Observe(v int64) {
+	m.t.observe(v)
+}
+
+// Load returns the largest value observed since the last Reset. ok is false
+// if there were no observations, or only of math.MinInt64.
+func (m *MaxTracker) Load() (max int64, ok bool) {
+	return m.t.merge(false)
+}
+
+// Reset forgets all observations and reports the values Load would return.
+func (m *MaxTracker) Reset() (max int64, ok bool) {
+	return m.t.merge(true)
+}
+
+// String returns the largest value in decimal, or null if Load reports no
+// value, so that MaxTracker implements expvar.Var.
+func (m *MaxTracker) String() string {
+	return m.t.String()
+}
+
+// NewMinTracker returns a MinTracker without observations.
+// The options configure the sharding of the MinTracker.
+func NewMinTracker(opts ...Option) *MinTracker {
+	return &MinTracker{t: newTracker(math.MaxInt64, func(a, b int64) bool { return a < b }, opts)}
+}
+
+// Observe records v.
+func (m *MinTracker) Observe(v int64) {
+	m.t.observe(v)
+}
+
+// Load returns the smallest value observed since the last Reset. ok is false
+// if there were no observations, or only of math.MaxInt64.
+func (m *MinTracker) Load() (min int64, ok bool) {
+	return m.t.merge(false)
+}
+
+// Reset forgets all observations and reports the values Load would return.
+func (m *MinTracker) Reset() (min int64, ok bool) {
+	return m.t.merge(true)
+}
+
+// String returns the smallest value in decimal, or null if Load reports no
+// value, so that MinTracker implements expvar.Var.
+func (m *MinTracker) String() string {
+	return m.t.String()
+}
@@ -0,0 +1,57 @@
+package percpu
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestMaxTracker(t *testing.T) {
+	m := NewMaxTracker()
+	if _, ok := m.Load(); ok {
+		t.Fatal("got ok without observations")
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				m.Observe(int64(i*100 + j - 500))
+			}
+		}(i)
+	}
+	wg.Wait()
+	if got, ok := m.Load(); got != 499 || !ok {
+		t.Fatalf("got %d, %v; want 499, true", got, ok)
+	}
+	if got, ok := m.Reset(); got != 499 || !ok {
+		t.Fatalf("Reset returned %d, %v; want 499, true", got, ok)
+	}
+	if _, ok := m.Load(); ok {
+		t.Fatal("got ok after Reset")
+	}
+	m.Observe(-7)
+	if got, ok := m.Load(); got != -7 || !ok {
+		t.Fatalf("got %d, %v after Reset; want -7, true", got, ok)
+	}
+}
+
+func TestMinTracker(t *testing.T) {
+	setProcIDs(t, 2, 0, 1, 0)
+	m := NewMinTracker()
+	m.Observe(5)
+	m.Observe(3)
+	m.Observe(4)
+	if got := *m.t.vs.Shard(0); got != 4 {
+		t.Fatalf("got %d in shard 0; want 4", got)
+	}
+	if got, ok := m.Load(); got != 3 || !ok {
+		t.Fatalf("got %d, %v; want 3, true", got, ok)
+	}
+	if got, ok := m.Reset(); got != 3 || !ok {
+		t.Fatalf("Reset returned %d, %v; want 3, true", got, ok)
+	}
+	if _, ok := m.Load(); ok {
+		t.Fatal("got ok after Reset")
+	}
+}
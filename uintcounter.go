@@ -0,0 +1,54 @@
+package percpu
+
+import (
+	"strconv"
+	"sync/atomic"
+)
+
+// A UintCounter is a uint64 counter which may be efficiently incremented
+// by many goroutines concurrently. It is useful for quantities that cannot be
+// negative and may exceed the range of int64, like byte or packet counts.
+// The total wraps around on overflow.
+//
+// Like Counter, Load and Reset do not observe a consistent view of the total
+// if they are called concurrently to Add. See Counter for the guarantees.
+type UintCounter struct {
+	vs Values[atomic.Uint64]
+}
+
+// NewUintCounter returns a fresh UintCounter initialized to zero.
+// The options configure the sharding of the UintCounter.
+func NewUintCounter(opts ...Option) *UintCounter {
+	c := &UintCounter{}
+	c.vs.opts.apply(opts)
+	return c
+}
+
+// Add adds n to the total count.
+func (c *UintCounter) Add(n uint64) {
+	c.vs.Get().Add(n)
+}
+
+// Load computes the total counter value.
+func (c *UintCounter) Load() uint64 {
+	var sum uint64
+	c.vs.Range(func(v *atomic.Uint64) {
+		sum += v.Load()
+	})
+	return sum
+}
+
+// Reset sets the counter to zero and reports the old value.
+func (c *UintCounter) Reset() uint64 {
+	var sum uint64
+	c.vs.Range(func(v *atomic.Uint64) {
+		sum += v.Swap(0)
+	})
+	return sum
+}
+
+// String returns the total count in decimal, so that UintCounter implements
+// expvar.Var.
+func (c *UintCounter) String() string {
+	return strconv.FormatUint(c.Load(), 10)
+}
@@ -0,0 +1,47 @@
+package percpu
+
+import (
+	"math"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestUintCounter(t *testing.T) {
+	c := NewUintCounter()
+	var wg sync.WaitGroup
+	const n = 100
+	var resetSum atomic.Uint64
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < n; i++ {
+				c.Add(1)
+				if i%20 == 0 {
+					resetSum.Add(c.Reset())
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if got, want := resetSum.Load()+c.Load(), uint64(n*n); got != want {
+		t.Fatalf("got total %d; want %d", got, want)
+	}
+}
+
+func TestUintCounterBeyondInt64(t *testing.T) {
+	setProcIDs(t, 2, 0, 1)
+	c := NewUintCounter()
+	c.Add(math.MaxInt64)
+	c.Add(math.MaxInt64)
+	if got, want := c.Load(), uint64(2*math.MaxInt64); got != want {
+		t.Fatalf("got %d; want %d", got, want)
+	}
+	if got := c.Reset(); got != 2*math.MaxInt64 {
+		t.Fatalf("Reset returned %d", got)
+	}
+	if got := c.Load(); got != 0 {
+		t.Fatalf("got %d after Reset", got)
+	}
+}
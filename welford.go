@@ -0,0 +1,123 @@
+package percpu
+
+import (
+	"math"
+	"sync"
+)
+
+// A Welford computes the mean and variance of observed float64 values and
+// may be efficiently updated by many goroutines concurrently.
+//
+// Unlike Summary, it does not keep a sum of squares, which loses precision
+// when the values are large compared to their spread. Each shard keeps the
+// running mean and the sum of squared differences from it, updated with
+// Welford's algorithm, and Load merges the shards with the parallel variant
+// of the algorithm by Chan et al.
+//
+// Like Counter, Load and Reset do not observe a consistent view of the shards
+// if they are called concurrently to Observe.
+type Welford struct {
+	vs Values[welfordShard]
+}
+
+type welfordShard struct {
+	mu sync.Mutex
+	s  WelfordSnapshot
+}
+
+// WelfordSnapshot holds the aggregated observations of a Welford.
+type WelfordSnapshot struct {
+	// Count is the number of observations.
+	Count int64
+	// Mean is the mean of the observed values, zero if there were none.
+	Mean float64
+	// M2 is the sum of squared differences of the observed values from
+	// Mean.
+	M2 float64
+}
+
+func (s *WelfordSnapshot) observe(x float64) {
+	s.Count++
+	delta := x - s.Mean
+	s.Mean += delta / float64(s.Count)
+	s.M2 += delta * (x - s.Mean)
+}
+
+// merge adds the observations of o to s.
+func (s *WelfordSnapshot) merge(o WelfordSnapshot) {
+	if o.Count == 0 {
+		return
+	}
+	if s.Count == 0 {
+		*s = o
+		return
+	}
+	n := s.Count + o.Count
+	delta := o.Mean - s.Mean
+	s.Mean += delta * float64(o.Count) / float64(n)
+	s.M2 += o.M2 + delta*delta*float64(s.Count)*float64(o.Count)/float64(n)
+	s.Count = n
+}
+
+// Variance returns the population variance of the observed values, or zero
+// if there were none.
+func (s WelfordSnapshot) Variance() float64 {
+	if s.Count == 0 {
+		return 0
+	}
+	return s.M2 / float64(s.Count)
+}
+
+// SampleVariance returns the unbiased sample variance of the observed values,
+// or zero if there were fewer than two.
+func (s WelfordSnapshot) SampleVariance() float64 {
+	if s.Count < 2 {
+		return 0
+	}
+	return s.M2 / float64(s.Count-1)
+}
+
+// StdDev returns the population standard deviation of the observed values,
+// or zero if there were none.
+func (s WelfordSnapshot) StdDev() float64 {
+	return math.Sqrt(s.Variance())
+}
+
+// NewWelford returns a Welford without observations.
+// The options configure the sharding of the Welford.
+func NewWelford(opts ...Option) *Welford {
+	w := &Welford{}
+	w.vs.opts.apply(opts)
+	return w
+}
+
+// Observe records x.
+func (w *Welford) Observe(x float64) {
+	sh := w.vs.Get()
+	sh.mu.Lock()
+	sh.s.observe(x)
+	sh.mu.Unlock()
+}
+
+// Load merges the observations of all shards.
+func (w *Welford) Load() WelfordSnapshot {
+	return w.merge(false)
+}
+
+// Reset forgets all observations and reports what Load would return.
+func (w *Welford) Reset() WelfordSnapshot {
+	return w.merge(true)
+}
+
+func (w *Welford) merge(reset bool) WelfordSnapshot {
+	var total WelfordSnapshot
+	w.vs.Range(func(sh *welfordShard) {
+		sh.mu.Lock()
+		total.merge(sh.s)
+		if reset {
+			sh.s = WelfordSnapshot{}
+		}
+		sh.mu.Unlock()
+	})
+	return total
+}
@@ -0,0 +1,52 @@
+package percpu
+
+import (
+	"math"
+	"sync"
+	"testing"
+)
+
+func TestWelford(t *testing.T) {
+	setProcIDs(t, 3, 0, 1, 2, 0, 1, 2, 0, 1)
+	w := NewWelford()
+	for _, x := range []float64{2, 4, 4, 4, 5, 5, 7, 9} {
+		w.Observe(x)
+	}
+	got := w.Load()
+	if got.Count != 8 || got.Mean != 5 || got.Variance() != 4 || got.StdDev() != 2 {
+		t.Fatalf("got %+v, variance %v; want count 8, mean 5, variance 4", got, got.Variance())
+	}
+	if v := got.SampleVariance(); math.Abs(v-32.0/7) > 1e-12 {
+		t.Fatalf("got sample variance %v; want %v", v, 32.0/7)
+	}
+	if r := w.Reset(); r != got {
+		t.Fatalf("Reset returned %+v; want %+v", r, got)
+	}
+	if got := w.Load(); got != (WelfordSnapshot{}) {
+		t.Fatalf("got %+v after Reset", got)
+	}
+}
+
+func TestWelfordPrecision(t *testing.T) {
+	w := NewWelford()
+	var wg sync.WaitGroup
+	// Values with a large offset and a variance of 1, for which the sum of
+	// squares used by Summary cancels catastrophically.
+	const offset = 1e9
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				x := offset + float64(j%2*2-1)
+				w.Observe(x)
+			}
+		}()
+	}
+	wg.Wait()
+	got := w.Load()
+	// Merging the shards rounds the mean to a few ulps of the offset.
+	if got.Count != 10000 || math.Abs(got.Mean-offset) > 1e-6 || math.Abs(got.Variance()-1) > 1e-6 {
+		t.Fatalf("got %+v, variance %v; want mean %v, variance 1", got, got.Variance(), offset)
+	}
+}